@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/pkg/storage/dynamo"
+)
+
+// migrate-subscriptions moves webhooks listed on vendor records into the subscriptions table
+// It's safe to run more than once. The old subscribers table is no longer read and can be deleted afterwards
+func main() {
+	persistentStorage := new(dynamo.Storage)
+
+	migrated, skipped, err := persistentStorage.MigrateLegacyWebhooks()
+	for _, subscription := range migrated {
+		color.Green("%v: %v subscribed to %v (%v)", subscription.Vendor, subscription.Endpoint, subscription.AttributePattern, subscription.Status)
+	}
+	for _, webhook := range skipped {
+		color.Yellow("%v: %v left on the vendor record: %v", webhook.Vendor, webhook.Endpoint, webhook.Reason)
+	}
+	if err != nil {
+		color.Red("Failed to migrate webhooks: %v", err)
+		os.Exit(1)
+	}
+
+	color.Cyan("Migrated %d webhook(s), skipped %d", len(migrated), len(skipped))
+}
@@ -1,30 +1,117 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/netguard"
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/auditing"
 	"github.com/super-type/supertype/pkg/authenticating"
+	"github.com/super-type/supertype/pkg/cataloging"
 	"github.com/super-type/supertype/pkg/consuming"
 	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/delivering"
 	"github.com/super-type/supertype/pkg/http/rest"
+	"github.com/super-type/supertype/pkg/idempotency"
+	"github.com/super-type/supertype/pkg/notifying"
 	"github.com/super-type/supertype/pkg/producing"
 	"github.com/super-type/supertype/pkg/storage/dynamo"
 )
 
+// shutdownTimeout is how long requests in progress get to finish when the server is shutting down
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialize storage
 	persistentStorage := new(dynamo.Storage)
 
+	// Load the attribute catalog, which every produce, consume and webhook is checked against
+	catalogDirectory := cataloging.Directory()
+	catalog, err := cataloging.Load(catalogDirectory)
+	if err != nil {
+		log.Fatalf("Failed to load attribute catalog: %v", err)
+	}
+	color.Cyan("Loaded %d attributes from %v", len(catalog.Attributes()), catalogDirectory)
+
+	// The webhook allowlist is for local development only
+	if err := netguard.CheckConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Start sending webhooks in the background
+	config := delivering.DefaultConfig()
+	if disableAfter := os.Getenv("WEBHOOK_DISABLE_AFTER"); disableAfter != "" {
+		d, err := time.ParseDuration(disableAfter)
+		if err != nil {
+			log.Fatalf("Invalid WEBHOOK_DISABLE_AFTER: %v", err)
+		}
+		config.DisableAfter = d
+	}
+	dispatcher := delivering.NewDispatcher(persistentStorage, config)
+	dispatcher.Start()
+
 	// Initialize services
 	authenticator := authenticating.NewService(persistentStorage)
-	dashboard := dashboard.NewService(persistentStorage)
-	producing := producing.NewService(persistentStorage)
-	consuming := consuming.NewService(persistentStorage)
+	verifier := delivering.NewVerifier(config.Timeout)
+	reverificationInterval := dashboard.ReverificationInterval
+	dashboard := dashboard.NewService(persistentStorage, verifier, catalog)
+	producing := producing.NewService(persistentStorage, dispatcher, catalog)
+	consuming := consuming.NewService(persistentStorage, catalog)
+	idempotency := idempotency.NewService(persistentStorage)
+	auditing := auditing.NewService(persistentStorage)
+	accessLog := accesslog.NewService(persistentStorage)
+	deliveries := delivering.NewService(persistentStorage, dispatcher)
+	notifications := notifying.NewService(persistentStorage)
+	attributes := cataloging.NewService(persistentStorage, catalog)
+
+	// Definitions changed through the catalog API take precedence over the files, and other instances' changes are picked up periodically
+	if err := attributes.Refresh(); err != nil {
+		color.Red("Failed to load stored attribute definitions: %v", err)
+	}
+	go func() {
+		for range time.Tick(cataloging.RefreshInterval) {
+			if err := attributes.Refresh(); err != nil {
+				color.Red("Failed to refresh attribute catalog: %v", err)
+			}
+		}
+	}()
+
+	// Periodically make sure webhook endpoints still belong to their vendors
+	go func() {
+		for range time.Tick(reverificationInterval) {
+			if err := dashboard.ReverifyWebhooks(); err != nil {
+				color.Red("Failed to re-verify webhooks: %v", err)
+			}
+		}
+	}()
 
 	// Initialize routers and startup server
-	httpRouter := rest.Router(authenticator, producing, consuming, dashboard)
-	color.Cyan("Starting HTTP server on port 5000...")
-	log.Fatal(http.ListenAndServe(":5000", httpRouter))
+	httpRouter := rest.Router(authenticator, producing, consuming, dashboard, idempotency, auditing, accessLog, deliveries, notifications, attributes)
+	server := &http.Server{Addr: ":5000", Handler: httpRouter}
+	go func() {
+		color.Cyan("Starting HTTP server on port 5000...")
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// On shutdown, finish the requests in progress before stopping the dispatcher, so nothing they queue is lost
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	color.Cyan("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		color.Red("Failed to shut down HTTP server cleanly: %v", err)
+	}
+	dispatcher.Stop()
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/storage/dynamo"
+)
+
+// verify-access-log checks users' hash-chained access logs for deleted or altered entries
+// Usage: verify-access-log -supertypeID <ID>, or verify-access-log -all
+func main() {
+	supertypeID := flag.String("supertypeID", "", "verify the access log of a single user")
+	all := flag.Bool("all", false, "verify the access log of every user")
+	flag.Parse()
+
+	if (*supertypeID == "") == !*all {
+		fmt.Fprintln(os.Stderr, "Exactly one of -supertypeID or -all is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	persistentStorage := new(dynamo.Storage)
+	accessLog := accesslog.NewService(persistentStorage)
+
+	supertypeIDs := []string{*supertypeID}
+	if *all {
+		var err error
+		supertypeIDs, err = persistentStorage.ListAccessLogUsers()
+		if err != nil {
+			color.Red("Failed to list access logs: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	failed := 0
+	for _, id := range supertypeIDs {
+		err := accessLog.Verify(id)
+		if err != nil {
+			failed++
+			color.Red("%v: %v", id, err)
+			continue
+		}
+		color.Green("%v: OK", id)
+	}
+
+	fmt.Printf("Verified %d access logs, %d failed\n", len(supertypeIDs), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -4,3 +4,15 @@ import "errors"
 
 // ErrFailedToGenerateKeys is used when we fail to generate vendor key-pair
 var ErrFailedToGenerateKeys = errors.New("Failed to generate vendor key-pair")
+
+// ErrFailedToGenerateWebhookSecret is used when we fail to generate a webhook signing secret
+var ErrFailedToGenerateWebhookSecret = errors.New("Failed to generate webhook signing secret")
+
+// ErrInvalidKey is used when a stored or supplied key can't be decoded
+var ErrInvalidKey = errors.New("Invalid key")
+
+// ErrMissingSealingKey is used when WEBHOOK_CREDENTIALS_KEY isn't set to a base64-encoded 32 byte key
+var ErrMissingSealingKey = errors.New("WEBHOOK_CREDENTIALS_KEY must be a base64-encoded 32 byte key")
+
+// ErrFailedToOpenSealed is used when an encrypted secret can't be decrypted, e.g. because the key changed
+var ErrFailedToOpenSealed = errors.New("Failed to decrypt stored secret")
@@ -5,6 +5,8 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"strings"
 )
@@ -32,3 +34,48 @@ func GenerateKeys() (*string, *string, error) {
 
 	return &skEncoded, &pkEncoded, nil
 }
+
+// GenerateWebhookSecret returns a new random secret used to sign webhook deliveries for a single subscription
+func GenerateWebhookSecret() (*string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	secret := "whsec_" + hex.EncodeToString(b)
+	return &secret, nil
+}
+
+// ParsePublicKey decodes a vendor public key produced by GenerateKeys
+func ParsePublicKey(pkEncoded string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(pkEncoded)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	pk, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	return pk, nil
+}
+
+// ParsePrivateKey decodes a vendor secret key produced by GenerateKeys
+func ParsePrivateKey(skEncoded string) (*ecdsa.PrivateKey, error) {
+	der, err := base64.StdEncoding.DecodeString(skEncoded)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	sk, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	return sk, nil
+}
@@ -0,0 +1,80 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+)
+
+// sealingKey returns the AES-256 key secrets are encrypted with at rest, read from WEBHOOK_CREDENTIALS_KEY as base64
+func sealingKey() ([]byte, error) {
+	encoded := os.Getenv("WEBHOOK_CREDENTIALS_KEY")
+	if encoded == "" {
+		return nil, ErrMissingSealingKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, ErrMissingSealingKey
+	}
+	return key, nil
+}
+
+// Seal encrypts a secret for storage with AES-256-GCM, returning the nonce and ciphertext as base64
+func Seal(plaintext []byte) (string, error) {
+	key, err := sealingKey()
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret encrypted by Seal
+func Open(sealed string) ([]byte, error) {
+	key, err := sealingKey()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, ErrFailedToOpenSealed
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrFailedToOpenSealed
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrFailedToOpenSealed
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,15 @@
+package netguard
+
+import "errors"
+
+// ErrInvalidURL is used when a webhook URL can't be parsed or has no host
+var ErrInvalidURL = errors.New("Invalid webhook URL")
+
+// ErrInsecureScheme is used when a webhook URL isn't HTTPS in production, or isn't HTTP(S) at all
+var ErrInsecureScheme = errors.New("Webhook URL must use HTTPS")
+
+// ErrBlockedAddress is used when a webhook host resolves to a loopback, link-local, private or metadata address
+var ErrBlockedAddress = errors.New("Webhook URL resolves to a blocked address")
+
+// ErrAllowlistInProduction is used when WEBHOOK_ALLOWED_HOSTS is set in production, where it's only meant for local development
+var ErrAllowlistInProduction = errors.New("WEBHOOK_ALLOWED_HOSTS can't be set in production")
@@ -0,0 +1,201 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// blockedRanges are the networks webhooks may never reach, so vendors can't point us at our own infrastructure
+var blockedRanges = parseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including cloud metadata at 169.254.169.254
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved, including broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // IPv4/IPv6 translation, which can reach any of the above
+	"fc00::/7",       // unique local, including cloud metadata at fd00:ec2::254
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// Production reports whether we're running in production, where webhooks must use HTTPS
+// Anything but an explicit SUPERTYPE_ENV=development counts, so a missing setting can't loosen the checks
+func Production() bool {
+	return os.Getenv("SUPERTYPE_ENV") != "development"
+}
+
+// Allowed reports whether a host is on the WEBHOOK_ALLOWED_HOSTS allowlist, a comma-separated list of hostnames
+// that skip the address checks, e.g. localhost during local development. The allowlist is ignored in production
+func Allowed(host string) bool {
+	if Production() {
+		return false
+	}
+	for _, allowed := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_HOSTS"), ",") {
+		if allowed = strings.TrimSpace(allowed); allowed != "" && strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckConfig refuses settings that would weaken the address checks in production
+func CheckConfig() error {
+	if Production() && strings.TrimSpace(os.Getenv("WEBHOOK_ALLOWED_HOSTS")) != "" {
+		return ErrAllowlistInProduction
+	}
+	return nil
+}
+
+// Blocked reports whether an IP address is in one of the ranges webhooks may never reach
+func Blocked(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, network := range blockedRanges {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unsafe reports whether an error means a webhook URL was refused, rather than the endpoint failing
+func Unsafe(err error) bool {
+	return errors.Is(err, ErrInvalidURL) || errors.Is(err, ErrInsecureScheme) || errors.Is(err, ErrBlockedAddress)
+}
+
+// ValidateURL checks a webhook URL before it's registered, resolving its host and rejecting it if any address is blocked
+func ValidateURL(rawURL string) error {
+	u, err := checkURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := u.Hostname()
+	if Allowed(host) {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if Blocked(ip) {
+			return fmt.Errorf("%w: %v", ErrBlockedAddress, ip)
+		}
+		return nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	for _, ip := range ips {
+		if Blocked(ip) {
+			return fmt.Errorf("%w: %v resolves to %v", ErrBlockedAddress, host, ip)
+		}
+	}
+
+	return nil
+}
+
+// checkURL parses a webhook URL and checks its scheme
+func checkURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, ErrInvalidURL
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if Production() {
+			return nil, ErrInsecureScheme
+		}
+	default:
+		return nil, ErrInsecureScheme
+	}
+
+	return u, nil
+}
+
+// NewClient creates an HTTP client for calling webhooks that refuses blocked addresses at connection time,
+// so a host that resolved to a public address at registration can't be re-pointed at our network later
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || Blocked(ip) {
+				return fmt.Errorf("%w: %v", ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	unchecked := &net.Dialer{Timeout: timeout}
+
+	transport := &http.Transport{
+		// Proxies would connect on our behalf, skipping the checks
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			host, _, err := net.SplitHostPort(address)
+			if err == nil && Allowed(host) {
+				return unchecked.DialContext(ctx, network, address)
+			}
+			return dialer.DialContext(ctx, network, address)
+		},
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: &schemeGuard{transport},
+		// Redirects aren't followed, since they'd carry the webhook's signature and credential headers to another host
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// schemeGuard refuses requests whose scheme isn't allowed
+type schemeGuard struct {
+	next http.RoundTripper
+}
+
+// RoundTrip checks the request's URL before passing it on
+func (g *schemeGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, err := checkURL(req.URL.String()); err != nil {
+		return nil, err
+	}
+	return g.next.RoundTrip(req)
+}
@@ -0,0 +1,161 @@
+package netguard
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// setenv sets an environment variable for the rest of the test, restoring it afterwards
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2606:4700:4700::1111", false},
+		{"0.0.0.0", true},
+		{"10.1.2.3", true},
+		{"100.64.0.1", true},
+		{"127.0.0.1", true},
+		{"169.254.169.254", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"198.18.0.1", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::", true},
+		{"::1", true},
+		// IPv4-mapped addresses are checked as the IPv4 address they carry
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:8.8.8.8", false},
+		{"64:ff9b::a00:1", true},
+		{"fd00:ec2::254", true},
+		{"fe80::1", true},
+		{"ff02::1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := net.ParseIP(tt.ip)
+			if ip == nil {
+				t.Fatalf("couldn't parse %q", tt.ip)
+			}
+			if got := Blocked(ip); got != tt.want {
+				t.Fatalf("Blocked(%v) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		production bool
+		wantErr    error
+	}{
+		{url: "https://example.com/hook", wantErr: nil},
+		{url: "http://example.com/hook", wantErr: nil},
+		{url: "https://example.com/hook", production: true, wantErr: nil},
+		{url: "http://example.com/hook", production: true, wantErr: ErrInsecureScheme},
+		{url: "ftp://example.com/hook", wantErr: ErrInsecureScheme},
+		{url: "example.com/hook", wantErr: ErrInvalidURL},
+		{url: "https:///hook", wantErr: ErrInvalidURL},
+		{url: "://example.com", wantErr: ErrInvalidURL},
+	}
+
+	for _, tt := range tests {
+		name := tt.url
+		if tt.production {
+			name += " in production"
+		}
+		t.Run(name, func(t *testing.T) {
+			if tt.production {
+				setenv(t, "SUPERTYPE_ENV", "production")
+			} else {
+				setenv(t, "SUPERTYPE_ENV", "development")
+			}
+
+			_, err := checkURL(tt.url)
+			if err != tt.wantErr {
+				t.Fatalf("checkURL(%q) error = %v, want %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllowedIgnoredInProduction(t *testing.T) {
+	setenv(t, "WEBHOOK_ALLOWED_HOSTS", "localhost, 127.0.0.1")
+
+	setenv(t, "SUPERTYPE_ENV", "development")
+	if !Allowed("localhost") || !Allowed("LOCALHOST") || !Allowed("127.0.0.1") {
+		t.Fatal("allowlisted hosts aren't allowed outside production")
+	}
+	if Allowed("example.com") {
+		t.Fatal("host that isn't allowlisted is allowed")
+	}
+	if err := CheckConfig(); err != nil {
+		t.Fatalf("CheckConfig() = %v outside production", err)
+	}
+
+	for _, env := range []string{"production", ""} {
+		setenv(t, "SUPERTYPE_ENV", env)
+		if Allowed("localhost") {
+			t.Fatalf("allowlist is honored with SUPERTYPE_ENV=%q", env)
+		}
+	}
+	if err := CheckConfig(); err != ErrAllowlistInProduction {
+		t.Fatalf("CheckConfig() = %v in production, want %v", err, ErrAllowlistInProduction)
+	}
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	setenv(t, "SUPERTYPE_ENV", "development")
+	setenv(t, "WEBHOOK_ALLOWED_HOSTS", "127.0.0.1")
+
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer webhook.Close()
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := NewClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("status = %v, want %v", resp.StatusCode, http.StatusTemporaryRedirect)
+	}
+	if followed {
+		t.Fatal("redirect was followed")
+	}
+}
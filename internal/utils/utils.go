@@ -2,10 +2,11 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -21,7 +22,11 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/fatih/color"
 	"github.com/joho/godotenv"
+	"github.com/super-type/supertype/pkg/auditing"
 	"github.com/super-type/supertype/pkg/authenticating"
+	httpUtil "github.com/super-type/supertype/pkg/http"
+	"github.com/super-type/supertype/pkg/idempotency"
+	"github.com/super-type/supertype/pkg/signing"
 	"github.com/super-type/supertype/pkg/storage"
 )
 
@@ -117,12 +122,21 @@ func ValidateEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
 
+// authorizedUsernameKey is the request context key IsAuthorized stores the vendor's username under
+type authorizedUsernameKey struct{}
+
+// AuthorizedUsername returns the username of the vendor whose JWT authorized the request, if IsAuthorized found one
+func AuthorizedUsername(r *http.Request) string {
+	username, _ := r.Context().Value(authorizedUsernameKey{}).(string)
+	return username
+}
+
 // IsAuthorized checks the given JWT to ensure vendor is authenticated
 func IsAuthorized(endpoint func(w http.ResponseWriter, r *http.Request)) func(http.ResponseWriter, *http.Request) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		(w).Header().Set("Access-Control-Allow-Origin", "*")
 		(w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		(w).Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, Token")
+		(w).Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, Token, X-Supertype-Timestamp, X-Supertype-Request-Signature, Idempotency-Key")
 		fmt.Printf("checking authed for %v\n", r.Header["Token"])
 		if r.Header["Token"] != nil {
 			token, err := jwt.Parse(r.Header["Token"][0], func(token *jwt.Token) (interface{}, error) {
@@ -145,6 +159,12 @@ func IsAuthorized(endpoint func(w http.ResponseWriter, r *http.Request)) func(ht
 			}
 
 			if token.Valid {
+				// Pass on who the token was issued to, for endpoints that tailor their response to the vendor
+				if claims, ok := token.Claims.(jwt.MapClaims); ok {
+					if username, ok := claims["user"].(string); ok {
+						r = r.WithContext(context.WithValue(r.Context(), authorizedUsernameKey{}, username))
+					}
+				}
 				endpoint(w, r)
 			}
 		} else {
@@ -153,38 +173,210 @@ func IsAuthorized(endpoint func(w http.ResponseWriter, r *http.Request)) func(ht
 	})
 }
 
-// GetAPIKeyHash returns the hashed value of the secret key
-func GetAPIKeyHash(skVendor string) string {
-	h := sha256.New()
-	h.Write([]byte(skVendor))
-	return hex.EncodeToString(h.Sum(nil))
+// IsSigned verifies ECDSA request signatures from vendors before calling the endpoint
+// Unsigned requests are let through unless REQUIRE_SIGNED_REQUESTS is set to "true"
+func IsSigned(a authenticating.Service, au auditing.Service, endpoint func(w http.ResponseWriter, r *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			endpoint(w, r)
+			return
+		}
+
+		signature := r.Header.Get(signing.RequestSignatureHeader)
+		if signature == "" {
+			if os.Getenv("REQUIRE_SIGNED_REQUESTS") == "true" {
+				http.Error(w, authenticating.ErrMissingRequestSignature.Error(), http.StatusUnauthorized)
+				return
+			}
+			endpoint(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		signedRequest := authenticating.SignedRequest{
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Timestamp: r.Header.Get(signing.RequestTimestampHeader),
+			Body:      body,
+			Signature: signature,
+		}
+
+		err = a.VerifyRequestSignature(signedRequest, r.Header.Get("X-API-Key"))
+		if err != nil {
+			au.Record(auditing.Event{
+				Type:    auditing.EventRequestSignatureInvalid,
+				Actor:   "apiKeyHash:" + GetAPIKeyHash(r.Header.Get("X-API-Key")),
+				IP:      httpUtil.ClientIP(r),
+				Outcome: auditing.OutcomeDenied,
+				Details: r.URL.Path + ": " + err.Error(),
+			})
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		endpoint(w, r)
+	})
+}
+
+// adminNameKey is the request context key IsAdmin stores the admin's name under
+type adminNameKey struct{}
+
+// AdminName returns the name of the admin whose key authorized the request, if IsAdmin found one
+func AdminName(r *http.Request) string {
+	name, _ := r.Context().Value(adminNameKey{}).(string)
+	return name
 }
 
-// ValidateNewSubscriberURL determines whether or not a requested Webhook URL already exists
-// TODO move this to a better file location on reorg
-func ValidateNewSubscriberURL(jsonString string, endpoint string) error {
-	if strings.Contains(jsonString, endpoint) {
-		color.Red("Webhook URL already subscribed")
-		return errors.New("Webhook URL already subscribed")
+// adminKeys returns the keys admins may use, by admin name
+// ADMIN_API_KEYS holds a comma separated name:key pair per admin, and a shared ADMIN_API_KEY is still accepted as "admin"
+func adminKeys() map[string]string {
+	keys := map[string]string{}
+	if adminKey := os.Getenv("ADMIN_API_KEY"); adminKey != "" {
+		keys["admin"] = adminKey
 	}
+	for _, pair := range strings.Split(os.Getenv("ADMIN_API_KEYS"), ",") {
+		name, key := "", ""
+		if i := strings.Index(pair, ":"); i > 0 {
+			name, key = strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		}
+		if name != "" && key != "" {
+			keys[name] = key
+		}
+	}
+	return keys
+}
+
+// IsAdmin only lets requests through whose X-Admin-Key header matches one of the admin keys, noting which admin it belongs to
+func IsAdmin(endpoint func(w http.ResponseWriter, r *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			return
+		}
+
+		given := []byte(r.Header.Get("X-Admin-Key"))
+		admin := ""
+		for name, key := range adminKeys() {
+			if subtle.ConstantTimeCompare(given, []byte(key)) == 1 {
+				admin = name
+			}
+		}
+		if admin == "" {
+			http.Error(w, authenticating.ErrNotAuthorized.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		endpoint(w, r.WithContext(context.WithValue(r.Context(), adminNameKey{}, admin)))
+	})
+}
+
+// responseRecorder passes a response through while keeping a copy so it can be stored
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
 
-	return nil
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
 }
 
-// AppendToSubscribers traverses levels of an attribute
-// TODO move this to a better file location on reorg
-func AppendToSubscribers(jsonString string, urls []interface{}, endpoint string) (*string, error) {
-	urls = append(urls, endpoint)
+// Idempotent honors the Idempotency-Key header, replaying the stored response for repeats of a key
+// Keys are scoped to the route and, when present, the vendor's API key
+func Idempotent(i idempotency.Service, endpoint func(w http.ResponseWriter, r *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return idempotent(i, endpoint, true)
+}
 
-	var result string
+// IdempotentOutcome honors the Idempotency-Key header for routes whose successful responses hold secrets, like private keys
+// Only the outcome is stored, so repeating a request that succeeded gets 409 rather than the secrets again
+func IdempotentOutcome(i idempotency.Service, endpoint func(w http.ResponseWriter, r *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return idempotent(i, endpoint, false)
+}
 
-	for _, url := range urls {
-		urlIndex := strings.Index(jsonString, url.(string))
-		if urlIndex != -1 {
-			quotation := urlIndex + strings.Index(jsonString[urlIndex:], `"`)
-			result = jsonString[0:quotation] + `","` + endpoint + `"` + jsonString[quotation+1:]
+// idempotent wraps an endpoint in idempotency key handling, storing successful response bodies only if replaySuccess is set
+func idempotent(i idempotency.Service, endpoint func(w http.ResponseWriter, r *http.Request), replaySuccess bool) func(http.ResponseWriter, *http.Request) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if r.Method == "OPTIONS" || idempotencyKey == "" {
+			endpoint(w, r)
+			return
 		}
-	}
 
-	return &result, nil
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key := r.URL.Path + ":" + idempotencyKey
+		if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+			key = r.URL.Path + ":" + GetAPIKeyHash(apiKey) + ":" + idempotencyKey
+		}
+		requestHash := sha256.Sum256(body)
+		requestHashEncoded := hex.EncodeToString(requestHash[:])
+
+		record, err := i.Begin(key, requestHashEncoded)
+		switch err {
+		case nil:
+		case idempotency.ErrRequestInProgress:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case idempotency.ErrKeyReused:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if record != nil {
+			if !replaySuccess && record.StatusCode < 300 {
+				http.Error(w, idempotency.ErrAlreadyCompleted.Error(), http.StatusConflict)
+				return
+			}
+			if record.ContentType != "" {
+				w.Header().Set("Content-Type", record.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write([]byte(record.Body))
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		endpoint(rec, r)
+
+		// Server errors are worth retrying, so don't pin them to the key
+		if rec.statusCode >= 500 {
+			err = i.Release(key)
+		} else if !replaySuccess && rec.statusCode < 300 {
+			err = i.Complete(key, requestHashEncoded, rec.statusCode, "", nil)
+		} else {
+			err = i.Complete(key, requestHashEncoded, rec.statusCode, w.Header().Get("Content-Type"), rec.body.Bytes())
+		}
+		if err != nil {
+			color.Red("Failed to store idempotency key: %v", err)
+		}
+	})
+}
+
+// GetAPIKeyHash returns the hashed value of the secret key
+func GetAPIKeyHash(skVendor string) string {
+	h := sha256.New()
+	h.Write([]byte(skVendor))
+	return hex.EncodeToString(h.Sum(nil))
 }
@@ -0,0 +1,76 @@
+package accesslog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GenesisHash is the previous hash of the first entry in every access log
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// HashEntry returns the hex SHA-256 of an entry's fields and the hash of the entry before it
+func HashEntry(e Entry) string {
+	h := sha256.Sum256([]byte(strings.Join([]string{
+		e.SupertypeID,
+		strconv.FormatInt(e.Sequence, 10),
+		e.Action,
+		e.Vendor,
+		e.Attribute,
+		e.Time,
+		e.PreviousHash,
+	}, "\n")))
+	return hex.EncodeToString(h[:])
+}
+
+// Next returns e chained after head, with its sequence, previous hash and hash filled in
+// A nil head starts a new chain
+func Next(head *Head, e Entry) Entry {
+	e.Sequence = 1
+	e.PreviousHash = GenesisHash
+	if head != nil {
+		e.Sequence = head.Sequence + 1
+		e.PreviousHash = head.Hash
+	}
+	e.Hash = HashEntry(e)
+	return e
+}
+
+// Verify walks a user's entries in sequence order and checks every link of the chain against the head
+// It returns a description of the first altered, missing or out-of-place entry it finds
+func Verify(entries []Entry, head *Head) error {
+	previousHash := GenesisHash
+	var sequence int64
+
+	for _, e := range entries {
+		sequence++
+		if e.Sequence != sequence {
+			return fmt.Errorf("%w: expected entry %d but found %d", ErrEntryMissing, sequence, e.Sequence)
+		}
+		if e.PreviousHash != previousHash {
+			return fmt.Errorf("%w: entry %d doesn't follow entry %d", ErrChainBroken, e.Sequence, e.Sequence-1)
+		}
+		if HashEntry(e) != e.Hash {
+			return fmt.Errorf("%w: entry %d", ErrEntryAltered, e.Sequence)
+		}
+		previousHash = e.Hash
+	}
+
+	if head == nil {
+		if len(entries) != 0 {
+			return ErrHeadMissing
+		}
+		return nil
+	}
+
+	if head.Sequence != sequence {
+		return fmt.Errorf("%w: log ends at entry %d but head is at entry %d", ErrEntryMissing, sequence, head.Sequence)
+	}
+	if head.Hash != previousHash {
+		return fmt.Errorf("%w: last entry doesn't match head", ErrEntryAltered)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package accesslog
+
+import (
+	"errors"
+	"testing"
+)
+
+// chain builds a user's access log of n entries, returning the entries and their head
+func chain(n int) ([]Entry, *Head) {
+	var entries []Entry
+	var head *Head
+	for i := 0; i < n; i++ {
+		e := Next(head, Entry{
+			SupertypeID: "user",
+			Action:      ActionConsume,
+			Vendor:      "vendor",
+			Attribute:   "kitchen/lights/status",
+			Time:        "2021-01-01T00:00:00Z",
+		})
+		entries = append(entries, e)
+		head = &Head{SupertypeID: e.SupertypeID, Sequence: e.Sequence, Hash: e.Hash}
+	}
+	return entries, head
+}
+
+func TestNext(t *testing.T) {
+	entries, _ := chain(2)
+
+	if entries[0].Sequence != 1 || entries[0].PreviousHash != GenesisHash {
+		t.Fatalf("first entry = %+v, want sequence 1 following the genesis hash", entries[0])
+	}
+	if entries[1].Sequence != 2 || entries[1].PreviousHash != entries[0].Hash {
+		t.Fatalf("second entry = %+v, want sequence 2 following the first", entries[1])
+	}
+	if entries[1].Hash != HashEntry(entries[1]) {
+		t.Fatal("entry hash doesn't match its fields")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]Entry, *Head) ([]Entry, *Head)
+		want   error
+	}{
+		{
+			name:   "intact",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) { return entries, head },
+		},
+		{
+			name:   "empty without head",
+			tamper: func([]Entry, *Head) ([]Entry, *Head) { return nil, nil },
+		},
+		{
+			name: "altered entry",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				entries[1].Vendor = "someone-else"
+				return entries, head
+			},
+			want: ErrEntryAltered,
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				entries[1].Vendor = "someone-else"
+				entries[1].Hash = HashEntry(entries[1])
+				return entries, head
+			},
+			want: ErrChainBroken,
+		},
+		{
+			name: "entry deleted from the middle",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				return append(entries[:1], entries[2:]...), head
+			},
+			want: ErrEntryMissing,
+		},
+		{
+			name: "entry deleted from the end",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				return entries[:len(entries)-1], head
+			},
+			want: ErrEntryMissing,
+		},
+		{
+			name: "every entry deleted",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				return nil, head
+			},
+			want: ErrEntryMissing,
+		},
+		{
+			name: "entries swapped",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				entries[0], entries[1] = entries[1], entries[0]
+				return entries, head
+			},
+			want: ErrEntryMissing,
+		},
+		{
+			name: "head missing",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				return entries, nil
+			},
+			want: ErrHeadMissing,
+		},
+		{
+			name: "head altered",
+			tamper: func(entries []Entry, head *Head) ([]Entry, *Head) {
+				head.Hash = GenesisHash
+				return entries, head
+			},
+			want: ErrEntryAltered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, head := tt.tamper(chain(3))
+			err := Verify(entries, head)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Verify() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package accesslog
+
+// Actions recorded in a user's access log
+const (
+	ActionConsume = "consume"
+	ActionWebhook = "webhook"
+)
+
+// Entry is one access to a user's data, chained to the entry before it by hash
+type Entry struct {
+	SupertypeID  string `json:"supertypeID"`
+	Sequence     int64  `json:"sequence"`
+	Action       string `json:"action"`
+	Vendor       string `json:"vendor"`
+	Attribute    string `json:"attribute"`
+	Time         string `json:"time"`
+	PreviousHash string `json:"previousHash"`
+	Hash         string `json:"hash"`
+}
+
+// Head is the latest entry of a user's access log, stored separately so deleting entries from the end is detectable
+type Head struct {
+	SupertypeID string `json:"supertypeID"`
+	Sequence    int64  `json:"sequence"`
+	Hash        string `json:"hash"`
+}
+
+// Log is a user's access log along with whether it passed verification
+type Log struct {
+	Entries  []Entry `json:"entries"`
+	Verified bool    `json:"verified"`
+	Problem  string  `json:"problem,omitempty"`
+}
@@ -0,0 +1,15 @@
+package accesslog
+
+import "errors"
+
+// ErrEntryMissing is used when an access log is missing entries
+var ErrEntryMissing = errors.New("Access log entry missing")
+
+// ErrEntryAltered is used when an access log entry no longer matches its hash
+var ErrEntryAltered = errors.New("Access log entry altered")
+
+// ErrChainBroken is used when an access log entry doesn't link to the entry before it
+var ErrChainBroken = errors.New("Access log chain broken")
+
+// ErrHeadMissing is used when an access log has entries but no head
+var ErrHeadMissing = errors.New("Access log head missing")
@@ -0,0 +1,49 @@
+package accesslog
+
+// Repository provides access to relevant storage
+type repository interface {
+	GetAccessLog(string) ([]Entry, *Head, error)
+}
+
+// Service provides access log operations
+type Service interface {
+	Get(string) (*Log, error)
+	Verify(string) error
+}
+
+type service struct {
+	r repository
+}
+
+// NewService creates an access log service with the necessary dependencies
+func NewService(r repository) Service {
+	return &service{r}
+}
+
+// Get returns a user's access log, verifying its chain on the way
+func (s *service) Get(supertypeID string) (*Log, error) {
+	entries, head, err := s.r.GetAccessLog(supertypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	log := Log{
+		Entries:  entries,
+		Verified: true,
+	}
+	if err := Verify(entries, head); err != nil {
+		log.Verified = false
+		log.Problem = err.Error()
+	}
+
+	return &log, nil
+}
+
+// Verify checks a user's access log for deleted or altered entries
+func (s *service) Verify(supertypeID string) error {
+	entries, head, err := s.r.GetAccessLog(supertypeID)
+	if err != nil {
+		return err
+	}
+	return Verify(entries, head)
+}
@@ -0,0 +1,6 @@
+package auditing
+
+import "errors"
+
+// ErrInvalidTimeFilter is used when an audit log time filter isn't RFC 3339
+var ErrInvalidTimeFilter = errors.New("Audit log time filters must be RFC 3339 timestamps")
@@ -0,0 +1,41 @@
+package auditing
+
+// Security-relevant event types recorded in the audit log
+const (
+	EventLoginFailed             = "login.failed"
+	EventAPIKeyMismatch          = "api-key.mismatch"
+	EventRequestSignatureInvalid = "request-signature.invalid"
+	EventObservationReplayed     = "observation.replayed"
+	EventKeyRotated              = "key.rotated"
+	EventConsentGranted          = "consent.granted"
+	EventWebhookRegistered       = "webhook.registered"
+)
+
+// Outcomes of an audited event
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// TimeFormat is a fixed-width RFC 3339 layout, so event times sort and compare as strings
+const TimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Event is a single entry in the security audit log
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Actor   string `json:"actor"`
+	IP      string `json:"ip"`
+	Time    string `json:"time"`
+	Outcome string `json:"outcome"`
+	Details string `json:"details,omitempty"`
+}
+
+// Filter narrows down which audit events are returned, empty fields match everything
+type Filter struct {
+	Type  string
+	Actor string
+	Since string
+	Until string
+}
@@ -0,0 +1,76 @@
+package auditing
+
+import (
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/uuid"
+)
+
+// Repository provides access to relevant storage
+type repository interface {
+	AppendAuditEvent(Event) error
+	ListAuditEvents(Filter) ([]Event, error)
+}
+
+// Service provides auditing operations
+type Service interface {
+	Record(Event)
+	List(Filter) ([]Event, error)
+}
+
+type service struct {
+	r repository
+}
+
+// NewService creates an auditing service with the necessary dependencies
+func NewService(r repository) Service {
+	return &service{r}
+}
+
+// Record appends an event to the audit log, stamping its ID and time
+// Failing to audit shouldn't fail the request being audited, so errors are only logged
+func (s *service) Record(e Event) {
+	e.ID = uuid.New().String()
+	e.Time = time.Now().UTC().Format(TimeFormat)
+
+	if e.Outcome != OutcomeSuccess {
+		color.Red("!!! %v by %v from %v: %v !!!", e.Type, e.Actor, e.IP, e.Outcome)
+	}
+
+	err := s.r.AppendAuditEvent(e)
+	if err != nil {
+		color.Red("Failed to write audit event: %v", err)
+	}
+}
+
+// List returns audit events matching the filter, oldest first
+func (s *service) List(f Filter) ([]Event, error) {
+	var err error
+	f.Since, err = normalizeTime(f.Since)
+	if err != nil {
+		return nil, err
+	}
+	f.Until, err = normalizeTime(f.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.r.ListAuditEvents(f)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// normalizeTime rewrites an RFC 3339 filter time in TimeFormat so it compares correctly with stored events
+func normalizeTime(t string) (string, error) {
+	if t == "" {
+		return "", nil
+	}
+	parsed, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return "", ErrInvalidTimeFilter
+	}
+	return parsed.UTC().Format(TimeFormat), nil
+}
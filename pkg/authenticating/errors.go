@@ -37,3 +37,9 @@ var ErrInvalidEmailMatching = errors.New("Invalid email - bad matching. Account
 
 // ErrInvalidEmail is used when an invalid email address is used to create an account
 var ErrInvalidEmail = errors.New("Invalid email address used. Account creation failed.")
+
+// ErrMissingRequestSignature is used when request signing is required but a request isn't signed
+var ErrMissingRequestSignature = errors.New("Request signature required")
+
+// ErrInvalidRequestSignature is used when a request signature doesn't verify against the vendor's public key
+var ErrInvalidRequestSignature = errors.New("Invalid request signature")
@@ -0,0 +1,10 @@
+package authenticating
+
+// SignedRequest defines the parts of an HTTP request a vendor signs with their secret key
+type SignedRequest struct {
+	Method    string
+	Path      string
+	Timestamp string
+	Body      []byte
+	Signature string
+}
@@ -1,5 +1,10 @@
 package authenticating
 
+import (
+	"github.com/super-type/supertype/internal/keys"
+	"github.com/super-type/supertype/pkg/signing"
+)
+
 // Repository provides access to relevant authentication storage
 // ? Should we capitalize repository? It seems to be best practice to do so... but I don't see why?
 type repository interface {
@@ -8,6 +13,7 @@ type repository interface {
 	CreateUser(UserPassword) (*string, error)
 	LoginUser(UserPassword) (*User, error)
 	AuthorizedLoginUser(UserPassword, string) (*User, error)
+	GetVendorPublicKey(string) (*string, error)
 }
 
 // Service provides authenticating operations
@@ -17,6 +23,7 @@ type Service interface {
 	CreateUser(UserPassword) (*string, error)
 	LoginUser(UserPassword) (*User, error)
 	AuthorizedLoginUser(UserPassword, string) (*User, error)
+	VerifyRequestSignature(SignedRequest, string) error
 }
 
 type service struct {
@@ -72,3 +79,23 @@ func (s *service) AuthorizedLoginUser(u UserPassword, apiKey string) (*User, err
 	}
 	return result, nil
 }
+
+// VerifyRequestSignature checks that a request was signed by the secret key belonging to the API key's vendor
+func (s *service) VerifyRequestSignature(req SignedRequest, apiKey string) error {
+	pkEncoded, err := s.r.GetVendorPublicKey(apiKey)
+	if err != nil {
+		return err
+	}
+
+	pk, err := keys.ParsePublicKey(*pkEncoded)
+	if err != nil {
+		return err
+	}
+
+	err = signing.VerifyRequest(pk, req.Method, req.Path, req.Timestamp, req.Body, req.Signature, signing.DefaultRequestWindow)
+	if err != nil {
+		return ErrInvalidRequestSignature
+	}
+
+	return nil
+}
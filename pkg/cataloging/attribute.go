@@ -0,0 +1,70 @@
+package cataloging
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/super-type/supertype/pkg/dashboard"
+)
+
+// Value types an attribute's observations can hold
+const (
+	TypeBoolean = "boolean"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeString  = "string"
+	TypeEnum    = "enum"
+)
+
+var valueTypes = map[string]bool{
+	TypeBoolean: true,
+	TypeNumber:  true,
+	TypeInteger: true,
+	TypeString:  true,
+	TypeEnum:    true,
+}
+
+// Attribute describes one attribute vendors can produce, consume and subscribe to, like master-bedroom/lights/status
+// Observations are encrypted end to end, so the type, unit and allowed values document what producers send
+// rather than being checked by us. Version counts up with every change made through the catalog API.
+// Owner, Visibility and Partners are only set on vendor attributes, under vendor/<owner>/
+type Attribute struct {
+	Path            string   `json:"path" yaml:"path"`
+	Description     string   `json:"description" yaml:"description"`
+	Type            string   `json:"type" yaml:"type"`
+	Unit            string   `json:"unit,omitempty" yaml:"unit,omitempty"`
+	AllowedValues   []string `json:"allowedValues,omitempty" yaml:"allowedValues,omitempty"`
+	Version         int      `json:"version" yaml:"version,omitempty"`
+	Deprecated      bool     `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	DeprecationNote string   `json:"deprecationNote,omitempty" yaml:"deprecationNote,omitempty"`
+	ReplacedBy      string   `json:"replacedBy,omitempty" yaml:"replacedBy,omitempty"`
+	UpdatedAt       string   `json:"updatedAt,omitempty" yaml:"-"`
+	UpdatedBy       string   `json:"updatedBy,omitempty" yaml:"-"`
+	Owner           string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Visibility      string   `json:"visibility,omitempty" yaml:"visibility,omitempty"`
+	Partners        []string `json:"partners,omitempty" yaml:"partners,omitempty"`
+}
+
+// Validate checks an attribute is a concrete path with a known value type, and normalizes its path
+func (a *Attribute) Validate() error {
+	path, err := dashboard.NormalizeAttributePattern(a.Path)
+	if err != nil || strings.ContainsAny(path, dashboard.SingleLevelWildcard+dashboard.MultiLevelWildcard) {
+		return fmt.Errorf("%w: %q is not a valid attribute path", ErrInvalidDefinition, a.Path)
+	}
+	a.Path = path
+	if a.Version == 0 {
+		a.Version = 1
+	}
+
+	if !valueTypes[a.Type] {
+		return fmt.Errorf("%w: %v has unknown type %q", ErrInvalidDefinition, a.Path, a.Type)
+	}
+	if a.Type == TypeEnum && len(a.AllowedValues) == 0 {
+		return fmt.Errorf("%w: enum %v has no allowed values", ErrInvalidDefinition, a.Path)
+	}
+	if a.Type != TypeEnum && a.Type != TypeString && len(a.AllowedValues) > 0 {
+		return fmt.Errorf("%w: %v can't restrict the values of a %v", ErrInvalidDefinition, a.Path, a.Type)
+	}
+
+	return a.validateOwnership()
+}
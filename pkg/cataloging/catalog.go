@@ -0,0 +1,183 @@
+package cataloging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/super-type/supertype/pkg/dashboard"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultDirectory is where attribute definition files are read from unless ATTRIBUTE_CATALOG_DIR says otherwise,
+// relative to the executable or, failing that, the working directory
+const DefaultDirectory = "configs/attributes"
+
+// Directory returns where attribute definition files are read from
+// The executable's directory comes first, so the catalog is found whichever directory the server is started from
+func Directory() string {
+	if directory := os.Getenv("ATTRIBUTE_CATALOG_DIR"); directory != "" {
+		return directory
+	}
+
+	// Binaries built somewhere temporary, like those from go run, fall back to the working directory
+	if executable, err := os.Executable(); err == nil {
+		directory := filepath.Join(filepath.Dir(executable), DefaultDirectory)
+		if info, err := os.Stat(directory); err == nil && info.IsDir() {
+			return directory
+		}
+	}
+	return DefaultDirectory
+}
+
+// Catalog holds every attribute in the Supertype ecosystem, and is safe to use from several goroutines
+type Catalog struct {
+	mu         sync.RWMutex
+	attributes map[string]Attribute
+}
+
+// definitionFile is the layout of an attribute definition file
+// Attribute paths are relative to every prefix, so a file can define the same devices for every room.
+// Without prefixes they're full paths
+type definitionFile struct {
+	Prefixes   []string    `json:"prefixes" yaml:"prefixes"`
+	Attributes []Attribute `json:"attributes" yaml:"attributes"`
+}
+
+// NewCatalog creates a catalog holding the given attributes
+func NewCatalog(attributes []Attribute) (*Catalog, error) {
+	c := &Catalog{attributes: map[string]Attribute{}}
+	for _, attribute := range attributes {
+		err := attribute.Validate()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.attributes[attribute.Path]; ok {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateAttribute, attribute.Path)
+		}
+		c.attributes[attribute.Path] = attribute
+	}
+	return c, nil
+}
+
+// Load creates a catalog from every .yaml, .yml and .json definition file in a directory
+func Load(directory string) (*Catalog, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []Attribute{}
+	for _, file := range files {
+		extension := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (extension != ".yaml" && extension != ".yml" && extension != ".json") {
+			continue
+		}
+
+		defined, err := loadFile(filepath.Join(directory, file.Name()), extension)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, defined...)
+	}
+
+	return NewCatalog(attributes)
+}
+
+// loadFile reads the attributes defined in one file, expanding them under each of its prefixes
+// Unknown fields are rejected, so a typo doesn't silently drop part of a definition
+func loadFile(path string, extension string) ([]Attribute, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := definitionFile{}
+	if extension == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&definitions)
+	} else {
+		err = yaml.UnmarshalStrict(data, &definitions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v: %v", ErrInvalidDefinition, path, err)
+	}
+
+	if len(definitions.Prefixes) == 0 {
+		return definitions.Attributes, nil
+	}
+
+	attributes := []Attribute{}
+	for _, prefix := range definitions.Prefixes {
+		for _, attribute := range definitions.Attributes {
+			attribute.Path = strings.Trim(prefix, "/") + "/" + strings.Trim(attribute.Path, "/")
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes, nil
+}
+
+// Put adds an attribute to the catalog, replacing any attribute with the same path
+func (c *Catalog) Put(attribute Attribute) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attributes[attribute.Path] = attribute
+}
+
+// Lookup returns the attribute with the given path
+func (c *Catalog) Lookup(path string) (Attribute, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	attribute, ok := c.attributes[strings.Trim(path, "/")]
+	return attribute, ok
+}
+
+// Attributes returns every attribute in the catalog, ordered by path
+func (c *Catalog) Attributes() []Attribute {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	attributes := make([]Attribute, 0, len(c.attributes))
+	for _, attribute := range c.attributes {
+		attributes = append(attributes, attribute)
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Path < attributes[j].Path
+	})
+	return attributes
+}
+
+// Visible reports whether an attribute is in the catalog and the vendor can use it
+func (c *Catalog) Visible(path string, vendor string) bool {
+	attribute, ok := c.Lookup(path)
+	return ok && attribute.VisibleTo(vendor)
+}
+
+// ValidateAttribute checks an observation's attribute is in the catalog and visible to the vendor
+// Attributes the vendor can't see are reported as unknown, so their names don't leak
+func (c *Catalog) ValidateAttribute(path string, vendor string) error {
+	if !c.Visible(path, vendor) {
+		return ErrUnknownAttribute
+	}
+	return nil
+}
+
+// ValidatePattern checks a webhook's attribute pattern matches at least one attribute in the catalog visible to the vendor
+func (c *Catalog) ValidatePattern(pattern string, vendor string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for path, attribute := range c.attributes {
+		if attribute.VisibleTo(vendor) && dashboard.PatternMatches(pattern, path) {
+			return nil
+		}
+	}
+	return ErrNoMatchingAttributes
+}
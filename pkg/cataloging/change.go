@@ -0,0 +1,40 @@
+package cataloging
+
+// Catalog change actions
+const (
+	ActionCreated    = "created"
+	ActionUpdated    = "updated"
+	ActionDeprecated = "deprecated"
+)
+
+// Change is one entry in the catalog's changelog, recording who changed an attribute, when, and what it became
+type Change struct {
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	Version    int       `json:"version"`
+	Action     string    `json:"action"`
+	Author     string    `json:"author"`
+	Note       string    `json:"note,omitempty"`
+	Time       string    `json:"time"`
+	Definition Attribute `json:"definition"`
+}
+
+// AttributeRequest defines an admin's request to create or update an attribute
+// Version is optional for updates, and if given must be the attribute's current version.
+// Author is the admin whose key authorized the request, never taken from the body
+type AttributeRequest struct {
+	Author    string    `json:"-"`
+	Note      string    `json:"note,omitempty"`
+	Version   int       `json:"version,omitempty"`
+	Attribute Attribute `json:"attribute"`
+}
+
+// DeprecateRequest defines an admin's request to deprecate an attribute, optionally naming the attribute replacing it
+// Author is the admin whose key authorized the request, never taken from the body
+type DeprecateRequest struct {
+	Path       string `json:"path"`
+	Author     string `json:"-"`
+	Note       string `json:"note,omitempty"`
+	ReplacedBy string `json:"replacedBy,omitempty"`
+	Version    int    `json:"version,omitempty"`
+}
@@ -0,0 +1,36 @@
+package cataloging
+
+import "errors"
+
+// ErrInvalidDefinition is used when an attribute definition is malformed, e.g. it has no path or an unknown value type
+var ErrInvalidDefinition = errors.New("Invalid attribute definition")
+
+// ErrDuplicateAttribute is used when the same attribute path is defined more than once
+var ErrDuplicateAttribute = errors.New("Attribute is defined more than once")
+
+// ErrUnknownAttribute is used when an observation or request names an attribute that isn't in the catalog
+var ErrUnknownAttribute = errors.New("Attribute is not in the catalog")
+
+// ErrNoMatchingAttributes is used when a webhook's attribute pattern doesn't match anything in the catalog
+var ErrNoMatchingAttributes = errors.New("Attribute pattern does not match any attribute in the catalog")
+
+// ErrMissingAuthor is used when a catalog change doesn't say who made it
+var ErrMissingAuthor = errors.New("Catalog changes must name their author")
+
+// ErrAttributeExists is used when creating an attribute that's already in the catalog
+var ErrAttributeExists = errors.New("Attribute is already in the catalog")
+
+// ErrVersionConflict is used when an attribute changed since the version a change was based on
+var ErrVersionConflict = errors.New("Attribute has changed since the given version")
+
+// ErrAlreadyDeprecated is used when deprecating an attribute that's already deprecated
+var ErrAlreadyDeprecated = errors.New("Attribute is already deprecated")
+
+// ErrInvalidReplacement is used when a deprecated attribute's replacement isn't a current attribute in the catalog
+var ErrInvalidReplacement = errors.New("Replacement attribute must be a different, current attribute in the catalog")
+
+// ErrInvalidListFilter is used when an attribute listing asks for an unknown format or deprecation status
+var ErrInvalidListFilter = errors.New("Invalid attribute listing filter")
+
+// ErrNotNamespaceOwner is used when a vendor defines an attribute outside their own vendor/<username>/ namespace
+var ErrNotNamespaceOwner = errors.New("Vendors can only define attributes under their own vendor namespace")
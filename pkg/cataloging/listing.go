@@ -0,0 +1,84 @@
+package cataloging
+
+import "strings"
+
+// Listing formats, a flat list ordered by path or a tree like the public catalog
+const (
+	FormatFlat = "flat"
+	FormatTree = "tree"
+)
+
+// ListFilter narrows down which attributes are listed, empty fields match everything
+// Prefix matches whole levels, so kitchen matches kitchen/lights/status but not kitchenette/lights/status.
+// Deprecated is "true" for only deprecated attributes, or "false" for only current ones
+type ListFilter struct {
+	Prefix     string
+	Room       string
+	Device     string
+	Deprecated string
+}
+
+// AttributeStats are a vendor's figures for one attribute
+// Observations counts what the vendor produced, Subscribers how many of their webhooks are subscribed to it
+type AttributeStats struct {
+	Observations int64 `json:"observations"`
+	Subscribers  int   `json:"subscribers"`
+}
+
+// ListedAttribute is an attribute in a listing, with where it sits and the calling vendor's figures for it
+type ListedAttribute struct {
+	Attribute
+	Room   string         `json:"room"`
+	Device string         `json:"device,omitempty"`
+	Stats  AttributeStats `json:"stats"`
+}
+
+// Room returns the first level of an attribute's path, like master-bedroom
+// Vendor attributes are read after their vendor/<owner>/ namespace
+func (a Attribute) Room() string {
+	return a.levels()[0]
+}
+
+// Device returns the second level of an attribute's path, like lights, or nothing for single-level paths
+func (a Attribute) Device() string {
+	levels := a.levels()
+	if len(levels) < 2 {
+		return ""
+	}
+	return levels[1]
+}
+
+// levels splits an attribute's path, leaving out any vendor namespace
+func (a Attribute) levels() []string {
+	levels := strings.Split(a.Path, "/")
+	if NamespaceOwner(a.Path) != "" && len(levels) > 2 {
+		return levels[2:]
+	}
+	return levels
+}
+
+// validate checks the filter's values, normalizing its prefix
+func (f *ListFilter) validate() error {
+	f.Prefix = strings.Trim(f.Prefix, "/")
+	if f.Deprecated != "" && f.Deprecated != "true" && f.Deprecated != "false" {
+		return ErrInvalidListFilter
+	}
+	return nil
+}
+
+// matches reports whether an attribute passes the filter
+func (f ListFilter) matches(attribute Attribute) bool {
+	if f.Prefix != "" && attribute.Path != f.Prefix && !strings.HasPrefix(attribute.Path, f.Prefix+"/") {
+		return false
+	}
+	if f.Room != "" && attribute.Room() != f.Room {
+		return false
+	}
+	if f.Device != "" && attribute.Device() != f.Device {
+		return false
+	}
+	if f.Deprecated != "" && attribute.Deprecated != (f.Deprecated == "true") {
+		return false
+	}
+	return true
+}
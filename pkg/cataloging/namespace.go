@@ -0,0 +1,115 @@
+package cataloging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VendorNamespace is the top level under which vendors define their own attributes, as vendor/<username>/...
+const VendorNamespace = "vendor"
+
+// Vendor attribute visibilities
+// Private attributes are only usable by their owner, shared ones also by the owner's partners,
+// and proposed ones by every vendor while they're considered for the public catalog
+const (
+	VisibilityPrivate  = "private"
+	VisibilityShared   = "shared"
+	VisibilityProposed = "proposed"
+)
+
+// VendorAttributeRequest defines a vendor's request to define or redefine one of their own attributes
+// Version is optional for redefinitions, and if given must be the attribute's current version
+type VendorAttributeRequest struct {
+	Note      string    `json:"note,omitempty"`
+	Version   int       `json:"version,omitempty"`
+	Attribute Attribute `json:"attribute"`
+}
+
+// NamespaceOwner returns the vendor owning an attribute path, or nothing for public catalog paths
+func NamespaceOwner(path string) string {
+	levels := strings.Split(strings.Trim(path, "/"), "/")
+	if len(levels) < 2 || levels[0] != VendorNamespace {
+		return ""
+	}
+	return levels[1]
+}
+
+// VisibleTo reports whether a vendor can produce, consume and subscribe to the attribute
+// An empty vendor only sees what's public, which includes attributes proposed for the public catalog
+func (a Attribute) VisibleTo(vendor string) bool {
+	if a.Owner == "" || a.Visibility == VisibilityProposed {
+		return true
+	}
+	if vendor == "" {
+		return false
+	}
+	if a.Owner == vendor {
+		return true
+	}
+	if a.Visibility != VisibilityShared {
+		return false
+	}
+	for _, partner := range a.Partners {
+		if partner == vendor {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOwnership checks only vendor attributes have an owner, that it matches their namespace, and their visibility
+func (a *Attribute) validateOwnership() error {
+	owner := NamespaceOwner(a.Path)
+	if owner == "" && strings.SplitN(a.Path, "/", 2)[0] == VendorNamespace {
+		return fmt.Errorf("%w: %v is missing the vendor it belongs to", ErrInvalidDefinition, a.Path)
+	}
+	if a.Owner != owner {
+		return fmt.Errorf("%w: %v can't be owned by %q", ErrInvalidDefinition, a.Path, a.Owner)
+	}
+
+	if owner == "" {
+		if a.Visibility != "" || len(a.Partners) > 0 {
+			return fmt.Errorf("%w: only vendor attributes have a visibility", ErrInvalidDefinition)
+		}
+		return nil
+	}
+	if a.Path == VendorNamespace+"/"+owner {
+		return fmt.Errorf("%w: %v is a namespace, not an attribute", ErrInvalidDefinition, a.Path)
+	}
+
+	if a.Visibility == "" {
+		a.Visibility = VisibilityPrivate
+	}
+	switch a.Visibility {
+	case VisibilityShared:
+		a.Partners = normalizePartners(a.Partners, owner)
+		if len(a.Partners) == 0 {
+			return fmt.Errorf("%w: shared attribute %v has no partners", ErrInvalidDefinition, a.Path)
+		}
+	case VisibilityPrivate, VisibilityProposed:
+		if len(a.Partners) > 0 {
+			return fmt.Errorf("%w: only shared attributes have partners", ErrInvalidDefinition)
+		}
+	default:
+		return fmt.Errorf("%w: %v has unknown visibility %q", ErrInvalidDefinition, a.Path, a.Visibility)
+	}
+
+	return nil
+}
+
+// normalizePartners trims, sorts and deduplicates partner usernames, dropping the owner
+func normalizePartners(partners []string, owner string) []string {
+	seen := map[string]bool{owner: true}
+	normalized := []string{}
+	for _, partner := range partners {
+		partner = strings.TrimSpace(partner)
+		if partner == "" || seen[partner] {
+			continue
+		}
+		seen[partner] = true
+		normalized = append(normalized, partner)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,297 @@
+package cataloging
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/uuid"
+	"github.com/super-type/supertype/pkg/dashboard"
+)
+
+// RefreshInterval is how often the catalog picks up changes made through other instances
+const RefreshInterval = time.Minute
+
+// Repository provides access to relevant storage
+type repository interface {
+	ListAttributeDefinitions() ([]Attribute, error)
+	PutAttributeChange(Change, int) error
+	ListCatalogChanges(string) ([]Change, error)
+	ListObservationCounts(string) (map[string]int64, error)
+	ListVendorSubscriptions(string) ([]dashboard.Subscription, error)
+	GetVendorUsername(string) (*string, error)
+}
+
+// Service provides attribute catalog operations
+type Service interface {
+	Tree() *Node
+	ListAttributes(ListFilter, string) ([]ListedAttribute, error)
+	Changelog(string) ([]Change, error)
+	CreateAttribute(AttributeRequest) (*Attribute, error)
+	UpdateAttribute(AttributeRequest) (*Attribute, error)
+	DeprecateAttribute(DeprecateRequest) (*Attribute, error)
+	DefineVendorAttribute(VendorAttributeRequest, string) (*Attribute, error)
+	Proposals() []Attribute
+	Refresh() error
+}
+
+type service struct {
+	r repository
+	c *Catalog
+}
+
+// NewService creates a catalog service with the necessary dependencies
+// Changes made through the service are applied to c, so everything validating against it sees them straight away
+func NewService(r repository, c *Catalog) Service {
+	return &service{r, c}
+}
+
+// Tree returns the public catalog as a tree, leaving out vendor attributes that are private or only shared with partners
+func (s *service) Tree() *Node {
+	return BuildTree(s.visible(""))
+}
+
+// ListAttributes lists the attributes visible to the vendor passing the filter, ordered by path, with the vendor's figures for each
+func (s *service) ListAttributes(filter ListFilter, vendor string) ([]ListedAttribute, error) {
+	err := filter.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.r.ListObservationCounts(vendor)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.r.ListVendorSubscriptions(vendor)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := []ListedAttribute{}
+	for _, attribute := range s.visible(vendor) {
+		if !filter.matches(attribute) {
+			continue
+		}
+
+		stats := AttributeStats{Observations: counts[attribute.Path]}
+		for _, subscription := range subscriptions {
+			if subscription.Active() && dashboard.PatternMatches(subscription.AttributePattern, attribute.Path) {
+				stats.Subscribers++
+			}
+		}
+
+		listed = append(listed, ListedAttribute{
+			Attribute: attribute,
+			Room:      attribute.Room(),
+			Device:    attribute.Device(),
+			Stats:     stats,
+		})
+	}
+
+	return listed, nil
+}
+
+// Changelog returns every recorded change to the public catalog, or to one attribute if path is set, oldest first
+// Changes to vendor attributes are left out while the attribute is private or only shared with partners
+func (s *service) Changelog(path string) ([]Change, error) {
+	changes, err := s.r.ListCatalogChanges(strings.Trim(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	public := []Change{}
+	for _, change := range changes {
+		attribute, ok := s.c.Lookup(change.Path)
+		if ok && attribute.VisibleTo("") {
+			public = append(public, change)
+		}
+	}
+	return public, nil
+}
+
+// CreateAttribute adds a new attribute to the public catalog at version 1
+func (s *service) CreateAttribute(attributeRequest AttributeRequest) (*Attribute, error) {
+	attribute := attributeRequest.Attribute
+	attribute.Version = 0
+	attribute.Deprecated = false
+	attribute.DeprecationNote = ""
+	attribute.ReplacedBy = ""
+	attribute.Owner = ""
+	attribute.Visibility = ""
+	attribute.Partners = nil
+	err := attribute.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.c.Lookup(attribute.Path); ok {
+		return nil, ErrAttributeExists
+	}
+
+	return s.write(attribute, 0, ActionCreated, attributeRequest.Author, attributeRequest.Note)
+}
+
+// UpdateAttribute replaces an attribute's definition, keeping whether it's deprecated and, for vendor attributes, who sees it
+func (s *service) UpdateAttribute(attributeRequest AttributeRequest) (*Attribute, error) {
+	attribute := attributeRequest.Attribute
+	current, ok := s.c.Lookup(attribute.Path)
+	if !ok {
+		return nil, ErrUnknownAttribute
+	}
+	attribute.Owner = current.Owner
+	attribute.Visibility = current.Visibility
+	attribute.Partners = current.Partners
+	err := attribute.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if attributeRequest.Version != 0 && attributeRequest.Version != current.Version {
+		return nil, ErrVersionConflict
+	}
+	attribute.Deprecated = current.Deprecated
+	attribute.DeprecationNote = current.DeprecationNote
+	attribute.ReplacedBy = current.ReplacedBy
+
+	return s.write(attribute, current.Version, ActionUpdated, attributeRequest.Author, attributeRequest.Note)
+}
+
+// DeprecateAttribute marks an attribute as deprecated
+// Deprecated attributes can still be produced, consumed and subscribed to, so integrations have time to move off them
+func (s *service) DeprecateAttribute(deprecateRequest DeprecateRequest) (*Attribute, error) {
+	attribute, ok := s.c.Lookup(deprecateRequest.Path)
+	if !ok {
+		return nil, ErrUnknownAttribute
+	}
+	if deprecateRequest.Version != 0 && deprecateRequest.Version != attribute.Version {
+		return nil, ErrVersionConflict
+	}
+	if attribute.Deprecated {
+		return nil, ErrAlreadyDeprecated
+	}
+
+	if deprecateRequest.ReplacedBy != "" {
+		replacement, ok := s.c.Lookup(deprecateRequest.ReplacedBy)
+		// Whoever can see the deprecated attribute needs to be able to see its replacement too
+		visible := replacement.VisibleTo("") || replacement.Owner == attribute.Owner
+		if !ok || !visible || replacement.Deprecated || replacement.Path == attribute.Path {
+			return nil, ErrInvalidReplacement
+		}
+		attribute.ReplacedBy = replacement.Path
+	}
+	attribute.Deprecated = true
+	attribute.DeprecationNote = deprecateRequest.Note
+
+	return s.write(attribute, attribute.Version, ActionDeprecated, deprecateRequest.Author, deprecateRequest.Note)
+}
+
+// DefineVendorAttribute creates or redefines an attribute under the vendor's own namespace
+// Redefining keeps whether the attribute is deprecated, but the vendor may change who sees it
+func (s *service) DefineVendorAttribute(attributeRequest VendorAttributeRequest, apiKey string) (*Attribute, error) {
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	attribute := attributeRequest.Attribute
+	if NamespaceOwner(attribute.Path) != *username {
+		return nil, ErrNotNamespaceOwner
+	}
+	attribute.Owner = *username
+	err = attribute.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	current, ok := s.c.Lookup(attribute.Path)
+	if !ok {
+		attribute.Version = 0
+		attribute.Deprecated = false
+		attribute.DeprecationNote = ""
+		attribute.ReplacedBy = ""
+		return s.write(attribute, 0, ActionCreated, *username, attributeRequest.Note)
+	}
+
+	if attributeRequest.Version != 0 && attributeRequest.Version != current.Version {
+		return nil, ErrVersionConflict
+	}
+	attribute.Deprecated = current.Deprecated
+	attribute.DeprecationNote = current.DeprecationNote
+	attribute.ReplacedBy = current.ReplacedBy
+
+	return s.write(attribute, current.Version, ActionUpdated, *username, attributeRequest.Note)
+}
+
+// Proposals returns the current vendor attributes proposed for the public catalog, ordered by path
+// Admins adopt one by creating a public attribute for it and deprecating the proposal in its favour
+func (s *service) Proposals() []Attribute {
+	proposals := []Attribute{}
+	for _, attribute := range s.c.Attributes() {
+		if attribute.Visibility == VisibilityProposed && !attribute.Deprecated {
+			proposals = append(proposals, attribute)
+		}
+	}
+	return proposals
+}
+
+// Refresh loads the attribute definitions changed through the catalog API, which take precedence over definition files
+// whatever their version, so the next change is made against the stored version
+func (s *service) Refresh() error {
+	stored, err := s.r.ListAttributeDefinitions()
+	if err != nil {
+		return err
+	}
+
+	for _, attribute := range stored {
+		s.c.Put(attribute)
+	}
+
+	return nil
+}
+
+// visible returns the attributes in the catalog the vendor can see, ordered by path
+func (s *service) visible(vendor string) []Attribute {
+	visible := []Attribute{}
+	for _, attribute := range s.c.Attributes() {
+		if attribute.VisibleTo(vendor) {
+			visible = append(visible, attribute)
+		}
+	}
+	return visible
+}
+
+// write stores the next version of an attribute, provided it's still at previousVersion, and records the change
+func (s *service) write(attribute Attribute, previousVersion int, action string, author string, note string) (*Attribute, error) {
+	author = strings.TrimSpace(author)
+	if author == "" {
+		return nil, ErrMissingAuthor
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	attribute.Version = previousVersion + 1
+	attribute.UpdatedAt = now
+	attribute.UpdatedBy = author
+
+	// The definition and its changelog entry are written together, so the catalog never changes without a record of it
+	err := s.r.PutAttributeChange(Change{
+		ID:         uuid.New().String(),
+		Path:       attribute.Path,
+		Version:    attribute.Version,
+		Action:     action,
+		Author:     author,
+		Note:       note,
+		Time:       now,
+		Definition: attribute,
+	}, previousVersion)
+	if err != nil {
+		// Another instance got there first, so catch up for the next attempt
+		if err == ErrVersionConflict {
+			if refreshErr := s.Refresh(); refreshErr != nil {
+				color.Red("Failed to refresh attribute catalog: %v", refreshErr)
+			}
+		}
+		return nil, err
+	}
+	s.c.Put(attribute)
+
+	return &attribute, nil
+}
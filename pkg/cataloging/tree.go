@@ -0,0 +1,73 @@
+package cataloging
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node is one level of the catalog tree, like master-bedroom or master-bedroom/lights
+// Nodes that are attributes themselves carry their definition, and in listings the calling vendor's figures for it
+type Node struct {
+	Name      string          `json:"name"`
+	Path      string          `json:"path"`
+	Attribute *Attribute      `json:"attribute,omitempty"`
+	Stats     *AttributeStats `json:"stats,omitempty"`
+	Children  []*Node         `json:"children,omitempty"`
+}
+
+// BuildTree arranges attributes into a tree with one node per path level, children ordered by name
+func BuildTree(attributes []Attribute) *Node {
+	root := &Node{}
+	for i := range attributes {
+		root.insert(attributes[i].Path).Attribute = &attributes[i]
+	}
+	root.sort()
+	return root
+}
+
+// BuildListingTree arranges listed attributes into a tree like BuildTree, keeping their figures
+func BuildListingTree(listed []ListedAttribute) *Node {
+	root := &Node{}
+	for i := range listed {
+		node := root.insert(listed[i].Path)
+		node.Attribute = &listed[i].Attribute
+		node.Stats = &listed[i].Stats
+	}
+	root.sort()
+	return root
+}
+
+// insert returns the node for a path beneath this one, adding any levels that are missing
+func (n *Node) insert(path string) *Node {
+	node := n
+	for _, level := range strings.Split(path, "/") {
+		node = node.child(level)
+	}
+	return node
+}
+
+// child returns the child with the given name, adding it if there isn't one yet
+func (n *Node) child(name string) *Node {
+	for _, child := range n.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+
+	path := name
+	if n.Path != "" {
+		path = n.Path + "/" + name
+	}
+	child := &Node{Name: name, Path: path}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+func (n *Node) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, child := range n.Children {
+		child.sort()
+	}
+}
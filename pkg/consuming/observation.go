@@ -8,9 +8,14 @@ type ObservationRequest struct {
 }
 
 // ObservationResponse defines an encrypted vendor observation response
+// Signature and Timestamp are only set when the producer signed the observation
+// Sequence counts the user's observations of the attribute, and is zero for observations stored before it was added
 type ObservationResponse struct {
 	Ciphertext  string `json:"ciphertext"`
 	DateAdded   string `json:"dateAdded"`
 	PublicKey   string `json:"pk"`
 	SupertypeID string `json:"supertypeID"`
+	Timestamp   int64  `json:"timestamp,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Sequence    int64  `json:"sequence,omitempty"`
 }
@@ -3,6 +3,7 @@ package consuming
 // Repository provides access to relevant storage
 type repository interface {
 	Consume(ObservationRequest, string) (*ObservationResponse, error)
+	GetVendorUsername(string) (*string, error)
 }
 
 // Service provides consuming operations
@@ -10,17 +11,32 @@ type Service interface {
 	Consume(ObservationRequest, string) (*ObservationResponse, error)
 }
 
+// Catalog knows which attributes exist and which vendors can see them
+type catalog interface {
+	ValidateAttribute(string, string) error
+}
+
 type service struct {
 	r repository
+	c catalog
 }
 
 // NewService creates a consuming service with the necessary dependencies
-func NewService(r repository) Service {
-	return &service{r}
+func NewService(r repository, c catalog) Service {
+	return &service{r, c}
 }
 
 // Consume consumes encrypted data from Supertype and returns it to vendors
 func (s *service) Consume(o ObservationRequest, apiKey string) (*ObservationResponse, error) {
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	err = s.c.ValidateAttribute(o.Attribute, *username)
+	if err != nil {
+		return nil, err
+	}
+
 	observation, err := s.r.Consume(o, apiKey)
 	if err != nil {
 		return nil, err
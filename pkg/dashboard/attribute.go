@@ -0,0 +1,85 @@
+package dashboard
+
+import "strings"
+
+// endpointQualifier marks where the attribute starts in webhook endpoints that don't name one explicitly
+const endpointQualifier = "supertype"
+
+// AttributeFromEndpoint returns the attribute encoded in an endpoint, everything after its /supertype/ segment
+func AttributeFromEndpoint(endpoint string) (string, error) {
+	levels := strings.Split(endpoint, "/")
+	for i := 0; i < len(levels); i++ {
+		if levels[i] == endpointQualifier {
+			return NormalizeAttributePattern(strings.Join(levels[i+1:], "/"))
+		}
+	}
+	return "", ErrInvalidAttributePattern
+}
+
+// Wildcard levels in attribute patterns, following MQTT topic filters
+const (
+	// SingleLevelWildcard matches exactly one level, so +/lights/status matches every room's light status
+	SingleLevelWildcard = "+"
+	// MultiLevelWildcard matches the level it's on and everything beneath it, so kitchen/# matches everything in the kitchen
+	MultiLevelWildcard = "#"
+)
+
+// NormalizeAttributePattern trims surrounding slashes from an attribute pattern, rejecting empty levels
+// and wildcards that aren't a whole level, or for #, aren't the last level
+func NormalizeAttributePattern(pattern string) (string, error) {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return "", ErrInvalidAttributePattern
+	}
+
+	levels := strings.Split(pattern, "/")
+	for i, level := range levels {
+		if level == "" {
+			return "", ErrInvalidAttributePattern
+		}
+		if level == MultiLevelWildcard && i != len(levels)-1 {
+			return "", ErrInvalidAttributePattern
+		}
+		if level != SingleLevelWildcard && level != MultiLevelWildcard && strings.ContainsAny(level, SingleLevelWildcard+MultiLevelWildcard) {
+			return "", ErrInvalidAttributePattern
+		}
+	}
+	return pattern, nil
+}
+
+// testLevel stands in for wildcard levels when a concrete attribute is needed for a pattern, e.g. in test events
+const testLevel = "test"
+
+// PatternMatches reports whether an attribute falls under an attribute pattern
+func PatternMatches(pattern string, attribute string) bool {
+	patternLevels := strings.Split(strings.Trim(pattern, "/"), "/")
+	levels := strings.Split(strings.Trim(attribute, "/"), "/")
+	for i, level := range patternLevels {
+		if level == MultiLevelWildcard {
+			return true
+		}
+		if i >= len(levels) || (level != SingleLevelWildcard && level != levels[i]) {
+			return false
+		}
+	}
+	return len(patternLevels) == len(levels)
+}
+
+// ExampleAttribute returns a concrete attribute matching a pattern, filling single-level wildcards with "test"
+// and dropping a trailing multi-level wildcard
+func ExampleAttribute(pattern string) string {
+	levels := []string{}
+	for _, level := range strings.Split(strings.Trim(pattern, "/"), "/") {
+		switch level {
+		case SingleLevelWildcard:
+			levels = append(levels, testLevel)
+		case MultiLevelWildcard:
+		default:
+			levels = append(levels, level)
+		}
+	}
+	if len(levels) == 0 {
+		return testLevel
+	}
+	return strings.Join(levels, "/")
+}
@@ -0,0 +1,101 @@
+package dashboard
+
+import "testing"
+
+func TestNormalizeAttributePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{pattern: "kitchen/lights/status", want: "kitchen/lights/status"},
+		{pattern: "/kitchen/lights/status/", want: "kitchen/lights/status"},
+		{pattern: "+/lights/status", want: "+/lights/status"},
+		{pattern: "kitchen/#", want: "kitchen/#"},
+		{pattern: "#", want: "#"},
+		{pattern: "+/+/#", want: "+/+/#"},
+		{pattern: "", wantErr: true},
+		{pattern: "/", wantErr: true},
+		{pattern: "kitchen//status", wantErr: true},
+		{pattern: "kitchen/#/status", wantErr: true},
+		{pattern: "kitchen/light+", wantErr: true},
+		{pattern: "kitchen/lights#", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := NormalizeAttributePattern(tt.pattern)
+			if tt.wantErr {
+				if err != ErrInvalidAttributePattern {
+					t.Fatalf("NormalizeAttributePattern(%q) error = %v, want %v", tt.pattern, err, ErrInvalidAttributePattern)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NormalizeAttributePattern(%q) = %q, %v, want %q", tt.pattern, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		attribute string
+		want      bool
+	}{
+		{"kitchen/lights/status", "kitchen/lights/status", true},
+		{"kitchen/lights/status", "/kitchen/lights/status/", true},
+		{"kitchen/lights/status", "kitchen/lights/color", false},
+		{"kitchen/lights/status", "kitchen/lights", false},
+		{"kitchen/lights", "kitchen/lights/status", false},
+		{"+/lights/status", "kitchen/lights/status", true},
+		{"+/lights/status", "kitchen/curtains/status", false},
+		{"+/lights/status", "lights/status", false},
+		{"kitchen/+", "kitchen/lights", true},
+		{"kitchen/+", "kitchen", false},
+		{"kitchen/+", "kitchen/lights/status", false},
+		// # matches its own level and everything beneath it, including its parent level
+		{"kitchen/#", "kitchen/lights/status", true},
+		{"kitchen/#", "kitchen/lights", true},
+		{"kitchen/#", "kitchen", true},
+		{"kitchen/#", "kitchenette/lights", false},
+		{"#", "kitchen/lights/status", true},
+		{"+/#", "kitchen", true},
+		{"+/lights/#", "kitchen/lights", true},
+		{"+/lights/#", "kitchen/curtains/status", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.attribute, func(t *testing.T) {
+			if got := PatternMatches(tt.pattern, tt.attribute); got != tt.want {
+				t.Fatalf("PatternMatches(%q, %q) = %v, want %v", tt.pattern, tt.attribute, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExampleAttribute(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"kitchen/lights/status", "kitchen/lights/status"},
+		{"+/lights/status", "test/lights/status"},
+		{"kitchen/#", "kitchen"},
+		{"+/#", "test"},
+		{"#", "test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := ExampleAttribute(tt.pattern)
+			if got != tt.want {
+				t.Fatalf("ExampleAttribute(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+			if !PatternMatches(tt.pattern, got) {
+				t.Fatalf("ExampleAttribute(%q) = %q, which the pattern doesn't match", tt.pattern, got)
+			}
+		})
+	}
+}
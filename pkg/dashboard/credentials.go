@@ -0,0 +1,95 @@
+package dashboard
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+)
+
+// Credential types
+const (
+	CredentialsBearer = "bearer"
+	CredentialsBasic  = "basic"
+)
+
+// headerName matches valid HTTP header names
+var headerName = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// reservedHeaders are set by us on every delivery, so subscriptions can't override them
+var reservedHeaders = map[string]bool{
+	"content-type":   true,
+	"content-length": true,
+	"host":           true,
+}
+
+// Credentials are what a subscription's endpoint needs to let our deliveries through, such as a gateway's bearer token
+// They're only ever stored encrypted, and are never returned to the vendor
+type Credentials struct {
+	Type     string            `json:"type,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// Validate checks the credentials are complete and their headers are safe to send
+func (c Credentials) Validate() error {
+	switch c.Type {
+	case "":
+	case CredentialsBearer:
+		if c.Token == "" {
+			return ErrInvalidCredentials
+		}
+	case CredentialsBasic:
+		if c.Username == "" {
+			return ErrInvalidCredentials
+		}
+	default:
+		return ErrInvalidCredentials
+	}
+
+	for name, value := range c.Headers {
+		lower := strings.ToLower(name)
+		if !headerName.MatchString(name) || reservedHeaders[lower] || strings.HasPrefix(lower, "x-supertype-") {
+			return ErrInvalidCredentials
+		}
+		// Authorization can be a custom header, but not on top of bearer or basic auth
+		if lower == "authorization" && c.Type != "" {
+			return ErrInvalidCredentials
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return ErrInvalidCredentials
+		}
+	}
+
+	return nil
+}
+
+// Description summarizes the credentials for the vendor without revealing them, e.g. "bearer+headers"
+func (c Credentials) Description() string {
+	parts := []string{}
+	if c.Type != "" {
+		parts = append(parts, c.Type)
+	}
+	if len(c.Headers) > 0 {
+		parts = append(parts, "headers")
+	}
+	return strings.Join(parts, "+")
+}
+
+// HTTPHeaders returns every header the credentials add to a delivery
+func (c Credentials) HTTPHeaders() map[string]string {
+	headers := map[string]string{}
+	for name, value := range c.Headers {
+		headers[name] = value
+	}
+
+	switch c.Type {
+	case CredentialsBearer:
+		headers["Authorization"] = "Bearer " + c.Token
+	case CredentialsBasic:
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+	}
+
+	return headers
+}
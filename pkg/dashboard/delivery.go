@@ -0,0 +1,61 @@
+package dashboard
+
+// Delivery modes, controlling how a subscription's observations are grouped into webhook requests
+const (
+	// DeliverySingle sends every observation in its own request
+	DeliverySingle = "single"
+	// DeliveryBatch sends observations together, once MaxEvents are waiting or MaxWaitMs have passed since the first
+	DeliveryBatch = "batch"
+	// DeliveryLatest sends observations one at a time, dropping any superseded by a newer one of the same user's attribute before they're sent
+	DeliveryLatest = "latest"
+)
+
+// Batching limits, and the defaults used when a batched subscription leaves them out
+const (
+	DefaultBatchMaxEvents = 100
+	MaxBatchMaxEvents     = 1000
+	DefaultBatchMaxWaitMs = 1000
+	MinBatchMaxWaitMs     = 10
+	MaxBatchMaxWaitMs     = 60000
+)
+
+// DeliveryMode defines how a subscription wants its observations delivered
+type DeliveryMode struct {
+	Mode      string `json:"mode"`
+	MaxEvents int    `json:"maxEvents,omitempty"`
+	MaxWaitMs int64  `json:"maxWaitMs,omitempty"`
+}
+
+// Batched reports whether observations are delivered in batches
+func (m DeliveryMode) Batched() bool {
+	return m.Mode == DeliveryBatch
+}
+
+// Coalesced reports whether superseded observations are dropped
+func (m DeliveryMode) Coalesced() bool {
+	return m.Mode == DeliveryLatest
+}
+
+// NormalizeDeliveryMode fills in defaults for a requested delivery mode, rejecting unknown modes and out of range limits
+func NormalizeDeliveryMode(m DeliveryMode) (DeliveryMode, error) {
+	switch m.Mode {
+	case "", DeliverySingle:
+		return DeliveryMode{Mode: DeliverySingle}, nil
+	case DeliveryLatest:
+		return DeliveryMode{Mode: DeliveryLatest}, nil
+	case DeliveryBatch:
+	default:
+		return DeliveryMode{}, ErrInvalidDeliveryMode
+	}
+
+	if m.MaxEvents == 0 {
+		m.MaxEvents = DefaultBatchMaxEvents
+	}
+	if m.MaxWaitMs == 0 {
+		m.MaxWaitMs = DefaultBatchMaxWaitMs
+	}
+	if m.MaxEvents < 1 || m.MaxEvents > MaxBatchMaxEvents || m.MaxWaitMs < MinBatchMaxWaitMs || m.MaxWaitMs > MaxBatchMaxWaitMs {
+		return DeliveryMode{}, ErrInvalidDeliveryMode
+	}
+	return m, nil
+}
@@ -2,8 +2,47 @@ package dashboard
 
 import "errors"
 
-// ErrDynamoInternalError is used when there is an internal service error in DynamoDB
-var ErrDynamoInternalError = errors.New("Internal server error in DynamoDB")
+// ErrSubscriptionNotFound is used when a vendor references a webhook subscription that doesn't exist
+var ErrSubscriptionNotFound = errors.New("Webhook subscription not found")
 
-// ErrDynamoError is used when there is an internal service error in DynamoDB
-var ErrDynamoError = errors.New("Internal server error in DynamoDB")
+// ErrSubscriptionNotOwned is used when a vendor references a webhook subscription belonging to another vendor
+var ErrSubscriptionNotOwned = errors.New("Webhook subscription belongs to another vendor")
+
+// ErrSubscriptionPaused is used when a vendor tries to verify a paused or disabled webhook subscription instead of resuming it
+var ErrSubscriptionPaused = errors.New("Webhook subscription is paused or disabled, resume it instead")
+
+// ErrSubscriptionNotPaused is used when a vendor tries to resume a webhook subscription that isn't paused or disabled
+var ErrSubscriptionNotPaused = errors.New("Webhook subscription is not paused or disabled")
+
+// ErrWebhookAlreadyRegistered is used when a vendor tries to subscribe an endpoint that's already subscribed
+var ErrWebhookAlreadyRegistered = errors.New("Webhook URL already subscribed")
+
+// ErrInvalidAttributePattern is used when a webhook's attribute pattern is empty or malformed
+var ErrInvalidAttributePattern = errors.New("Invalid attribute pattern")
+
+// ErrInvalidDeliveryMode is used when a webhook asks for an unknown delivery mode or batch limits out of range
+var ErrInvalidDeliveryMode = errors.New("Invalid delivery mode")
+
+// ErrInvalidCredentials is used when a webhook's outbound credentials are incomplete or set headers we don't allow
+var ErrInvalidCredentials = errors.New("Invalid webhook credentials")
+
+// ErrAttributeNotSubscribed is used when a test event's attribute doesn't match the webhook's attribute pattern
+var ErrAttributeNotSubscribed = errors.New("Attribute does not match the webhook's attribute pattern")
+
+// ErrSubscriptionNotVerified is used when a vendor test-fires a webhook whose endpoint has never passed a challenge
+var ErrSubscriptionNotVerified = errors.New("Webhook has not been verified, verify it before sending test events")
+
+// ErrInvalidScope is used when a webhook's scope lists both users and user tags, or more entries than allowed
+var ErrInvalidScope = errors.New("Invalid webhook scope")
+
+// ErrInvalidUserTags is used when a vendor gives a user too many tags, or tags that are too long
+var ErrInvalidUserTags = errors.New("Invalid user tags")
+
+// ErrUserNotConnected is used when a vendor tags a user who hasn't connected to them
+var ErrUserNotConnected = errors.New("User is not connected to this vendor")
+
+// ErrInvalidSchemaVersion is used when a webhook asks for an event schema version we don't send
+var ErrInvalidSchemaVersion = errors.New("Invalid webhook schema version")
+
+// ErrReverificationIncomplete is used when some subscriptions couldn't be re-verified, e.g. because their status couldn't be stored
+var ErrReverificationIncomplete = errors.New("Some webhooks could not be re-verified")
@@ -0,0 +1,138 @@
+package dashboard
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexRefreshInterval is how long a subscription index is trusted before it's reloaded from storage,
+// so changes made by other instances are picked up
+const IndexRefreshInterval = time.Minute
+
+// Index resolves the subscriptions matching an attribute without scanning every subscription
+// Subscriptions are kept in a tree with one level per pattern level, wildcards included, so a lookup only walks
+// the branches an attribute could match
+type Index struct {
+	mu         sync.RWMutex
+	root       *indexNode
+	byEndpoint map[string]Subscription
+}
+
+// indexNode is one attribute level, holding the subscriptions whose pattern ends there by endpoint
+type indexNode struct {
+	children      map[string]*indexNode
+	subscriptions map[string]Subscription
+}
+
+// NewIndex creates an index holding the given subscriptions
+func NewIndex(subscriptions []Subscription) *Index {
+	index := &Index{
+		root:       newIndexNode(),
+		byEndpoint: map[string]Subscription{},
+	}
+	for _, subscription := range subscriptions {
+		index.put(subscription)
+	}
+	return index
+}
+
+func newIndexNode() *indexNode {
+	return &indexNode{
+		children:      map[string]*indexNode{},
+		subscriptions: map[string]Subscription{},
+	}
+}
+
+// Put adds a subscription to the index, replacing any subscription with the same endpoint
+func (i *Index) Put(subscription Subscription) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.put(subscription)
+}
+
+// Remove takes the subscription for an endpoint out of the index
+func (i *Index) Remove(endpoint string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(endpoint)
+}
+
+// Match returns every subscription whose attribute pattern matches the attribute
+func (i *Index) Match(attribute string) []Subscription {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var matches []Subscription
+	i.root.match(strings.Split(strings.Trim(attribute, "/"), "/"), &matches)
+	return matches
+}
+
+// match collects the subscriptions beneath this node matching the remaining levels of an attribute
+// Only the exact level and the wildcards are followed at each level, so the walk stays within the attribute's own paths
+func (n *indexNode) match(levels []string, matches *[]Subscription) {
+	// # also matches its parent level, so kitchen/# matches kitchen itself
+	if child := n.children[MultiLevelWildcard]; child != nil {
+		for _, subscription := range child.subscriptions {
+			*matches = append(*matches, subscription)
+		}
+	}
+
+	if len(levels) == 0 {
+		for _, subscription := range n.subscriptions {
+			*matches = append(*matches, subscription)
+		}
+		return
+	}
+
+	if child := n.children[levels[0]]; child != nil {
+		child.match(levels[1:], matches)
+	}
+	if child := n.children[SingleLevelWildcard]; child != nil {
+		child.match(levels[1:], matches)
+	}
+}
+
+func (i *Index) put(subscription Subscription) {
+	i.remove(subscription.Endpoint)
+
+	node := i.root
+	for _, level := range strings.Split(subscription.AttributePattern, "/") {
+		child := node.children[level]
+		if child == nil {
+			child = newIndexNode()
+			node.children[level] = child
+		}
+		node = child
+	}
+	node.subscriptions[subscription.Endpoint] = subscription
+	i.byEndpoint[subscription.Endpoint] = subscription
+}
+
+func (i *Index) remove(endpoint string) {
+	subscription, ok := i.byEndpoint[endpoint]
+	if !ok {
+		return
+	}
+	delete(i.byEndpoint, endpoint)
+
+	// Remember the path so levels left empty can be pruned on the way back up
+	path := []*indexNode{i.root}
+	levels := strings.Split(subscription.AttributePattern, "/")
+	for _, level := range levels {
+		node := path[len(path)-1].children[level]
+		if node == nil {
+			return
+		}
+		path = append(path, node)
+	}
+	delete(path[len(path)-1].subscriptions, endpoint)
+
+	for j := len(levels) - 1; j >= 0; j-- {
+		node := path[j+1]
+		if len(node.subscriptions) > 0 || len(node.children) > 0 {
+			break
+		}
+		delete(path[j].children, levels[j])
+	}
+}
@@ -0,0 +1,106 @@
+package dashboard
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// matchedEndpoints returns the endpoints of the subscriptions matching an attribute, sorted
+func matchedEndpoints(index *Index, attribute string) []string {
+	endpoints := []string{}
+	for _, subscription := range index.Match(attribute) {
+		endpoints = append(endpoints, subscription.Endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+func TestIndexMatch(t *testing.T) {
+	index := NewIndex([]Subscription{
+		{Endpoint: "exact", AttributePattern: "kitchen/lights/status"},
+		{Endpoint: "any-room", AttributePattern: "+/lights/status"},
+		{Endpoint: "kitchen", AttributePattern: "kitchen/#"},
+		{Endpoint: "everything", AttributePattern: "#"},
+		{Endpoint: "room-devices", AttributePattern: "+/+"},
+		{Endpoint: "lights-beneath", AttributePattern: "+/lights/#"},
+	})
+
+	tests := []struct {
+		attribute string
+		want      []string
+	}{
+		{"kitchen/lights/status", []string{"any-room", "everything", "exact", "kitchen", "lights-beneath"}},
+		{"/kitchen/lights/status/", []string{"any-room", "everything", "exact", "kitchen", "lights-beneath"}},
+		{"hall/lights/status", []string{"any-room", "everything", "lights-beneath"}},
+		{"kitchen/lights", []string{"everything", "kitchen", "lights-beneath", "room-devices"}},
+		{"kitchen", []string{"everything", "kitchen"}},
+		{"hall/curtains/status", []string{"everything"}},
+		{"hall/lights/status/extra", []string{"everything", "lights-beneath"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.attribute, func(t *testing.T) {
+			got := matchedEndpoints(index, tt.attribute)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Match(%q) = %v, want %v", tt.attribute, got, tt.want)
+			}
+
+			// The index must agree with matching each pattern on its own
+			for _, endpoint := range got {
+				subscription := index.byEndpoint[endpoint]
+				if !PatternMatches(subscription.AttributePattern, tt.attribute) {
+					t.Errorf("Match(%q) returned %v, but PatternMatches(%q) disagrees", tt.attribute, endpoint, subscription.AttributePattern)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexPutReplaces(t *testing.T) {
+	index := NewIndex([]Subscription{{Endpoint: "a", AttributePattern: "kitchen/lights/status"}})
+	index.Put(Subscription{Endpoint: "a", AttributePattern: "hall/#"})
+
+	if got := matchedEndpoints(index, "kitchen/lights/status"); len(got) != 0 {
+		t.Fatalf("old pattern still matches %v", got)
+	}
+	if got := matchedEndpoints(index, "hall/lights/status"); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("new pattern matches %v, want [a]", got)
+	}
+	if _, ok := index.root.children["kitchen"]; ok {
+		t.Fatal("levels left empty by the old pattern weren't pruned")
+	}
+}
+
+func TestIndexRemovePrunes(t *testing.T) {
+	index := NewIndex([]Subscription{
+		{Endpoint: "a", AttributePattern: "kitchen/lights/status"},
+		{Endpoint: "b", AttributePattern: "kitchen/lights"},
+		{Endpoint: "c", AttributePattern: "kitchen/#"},
+	})
+
+	index.Remove("a")
+	lights := index.root.children["kitchen"].children["lights"]
+	if lights == nil {
+		t.Fatal("level still holding a subscription was pruned")
+	}
+	if _, ok := lights.children["status"]; ok {
+		t.Fatal("empty level wasn't pruned")
+	}
+
+	index.Remove("b")
+	if _, ok := index.root.children["kitchen"].children["lights"]; ok {
+		t.Fatal("empty level wasn't pruned")
+	}
+	if got := matchedEndpoints(index, "kitchen/lights"); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Fatalf("Match after removals = %v, want [c]", got)
+	}
+
+	index.Remove("c")
+	if len(index.root.children) != 0 || len(index.byEndpoint) != 0 {
+		t.Fatal("index isn't empty after removing every subscription")
+	}
+
+	// Removing an endpoint that isn't indexed is a no-op
+	index.Remove("missing")
+}
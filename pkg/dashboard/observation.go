@@ -8,6 +8,98 @@ type ObservationRequest struct {
 }
 
 // WebhookRequest defines a request Webhook from a vendor
+// Attribute, Delivery, Scope, SchemaVersion and Credentials are only read on registration. Attribute defaults to everything
+// after /supertype/ in the endpoint, Delivery to sending every observation on its own, Scope to every connected user and
+// producer, and SchemaVersion to the latest
 type WebhookRequest struct {
+	Endpoint      string        `json:"endpoint"`
+	Attribute     string        `json:"attribute,omitempty"`
+	Delivery      *DeliveryMode `json:"delivery,omitempty"`
+	Scope         *Scope        `json:"scope,omitempty"`
+	SchemaVersion int           `json:"schemaVersion,omitempty"`
+	Credentials   *Credentials  `json:"credentials,omitempty"`
+}
+
+// RotateSecretRequest defines a vendor's request to rotate a webhook signing secret
+type RotateSecretRequest struct {
+	Endpoint       string `json:"endpoint"`
+	OverlapSeconds int64  `json:"overlapSeconds"`
+}
+
+// UpdateWebhookRequest defines a vendor's request to change a webhook subscription's endpoint, attribute pattern, delivery mode,
+// scope or event schema version. A Scope replaces the subscription's whole scope, so an empty one removes it
+type UpdateWebhookRequest struct {
+	Endpoint      string        `json:"endpoint"`
+	NewEndpoint   string        `json:"newEndpoint,omitempty"`
+	Attribute     string        `json:"attribute,omitempty"`
+	Delivery      *DeliveryMode `json:"delivery,omitempty"`
+	Scope         *Scope        `json:"scope,omitempty"`
+	SchemaVersion int           `json:"schemaVersion,omitempty"`
+}
+
+// CredentialsRequest defines a vendor's request to set the credentials sent with a webhook's deliveries
+// Leaving Credentials out removes any credentials already set
+type CredentialsRequest struct {
+	Endpoint    string       `json:"endpoint"`
+	Credentials *Credentials `json:"credentials,omitempty"`
+}
+
+// TestWebhookRequest defines a vendor's request to send a synthetic event to one of their webhooks
+// Attribute must match the subscription's pattern, and defaults to one that does
+type TestWebhookRequest struct {
+	Endpoint  string `json:"endpoint"`
+	Attribute string `json:"attribute,omitempty"`
+}
+
+// UserTagsRequest defines a vendor's request to replace the tags they gave one of their users
+type UserTagsRequest struct {
+	SupertypeID string   `json:"supertypeID"`
+	Tags        []string `json:"tags"`
+}
+
+// VerifyRequest defines a vendor's request to re-run the ownership check of a webhook endpoint
+type VerifyRequest struct {
 	Endpoint string `json:"endpoint"`
 }
+
+// WebhookSecret is returned to the vendor whenever a webhook signing secret is issued
+type WebhookSecret struct {
+	Endpoint                string `json:"endpoint"`
+	Secret                  string `json:"secret"`
+	PreviousSecretExpiresAt int64  `json:"previousSecretExpiresAt,omitempty"`
+	Status                  string `json:"status,omitempty"`
+	VerificationError       string `json:"verificationError,omitempty"`
+}
+
+// VerificationResult is returned to the vendor after checking they own a webhook endpoint
+type VerificationResult struct {
+	Endpoint          string `json:"endpoint"`
+	Status            string `json:"status"`
+	VerificationError string `json:"verificationError,omitempty"`
+}
+
+// TestDeliveryResult is returned to the vendor after test-firing a webhook, describing how their endpoint responded
+type TestDeliveryResult struct {
+	Endpoint      string `json:"endpoint"`
+	DeliveryID    string `json:"deliveryID"`
+	Attribute     string `json:"attribute"`
+	StatusCode    int    `json:"statusCode,omitempty"`
+	LatencyMs     int64  `json:"latencyMs"`
+	Body          string `json:"body,omitempty"`
+	BodyTruncated bool   `json:"bodyTruncated,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Webhook is a vendor's view of one of their webhook subscriptions
+type Webhook struct {
+	ID               string       `json:"id"`
+	AttributePattern string       `json:"attributePattern"`
+	Endpoint         string       `json:"endpoint"`
+	Delivery         DeliveryMode `json:"delivery"`
+	Scope            Scope        `json:"scope"`
+	SchemaVersion    int          `json:"schemaVersion"`
+	Status           string       `json:"status"`
+	VerifiedAt       string       `json:"verifiedAt,omitempty"`
+	CreatedAt        string       `json:"createdAt,omitempty"`
+	Credentials      string       `json:"credentials,omitempty"`
+}
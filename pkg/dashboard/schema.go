@@ -0,0 +1,22 @@
+package dashboard
+
+// Webhook event schema versions, subscriptions are pinned to one until their vendor upgrades them
+const (
+	// SchemaVersionLegacy is the original flat body of string fields, kept for subscriptions made before versioning
+	SchemaVersionLegacy = 1
+	// SchemaVersionEnvelope wraps every observation in an event envelope with an ID, type and RFC 3339 times
+	SchemaVersionEnvelope = 2
+	// LatestSchemaVersion is what new subscriptions get unless they ask for another version
+	LatestSchemaVersion = SchemaVersionEnvelope
+)
+
+// NormalizeSchemaVersion defaults an unset schema version to the latest, rejecting versions we don't send
+func NormalizeSchemaVersion(version int) (int, error) {
+	if version == 0 {
+		return LatestSchemaVersion, nil
+	}
+	if version < SchemaVersionLegacy || version > LatestSchemaVersion {
+		return 0, ErrInvalidSchemaVersion
+	}
+	return version, nil
+}
@@ -0,0 +1,113 @@
+package dashboard
+
+import (
+	"sort"
+	"strings"
+)
+
+// Scope limits
+const (
+	MaxScopeSupertypeIDs = 1000
+	MaxScopeUserTags     = 50
+	MaxScopeProducers    = 100
+	MaxUserTags          = 50
+	MaxUserTagLength     = 64
+)
+
+// Scope narrows which observations a subscription receives beyond its attribute pattern
+// SupertypeIDs and UserTags are alternatives: a subscription lists the users it wants, or the tags its vendor gave them,
+// and receives the observations of users with any of those tags. Producers lists the usernames of the vendors whose
+// observations it wants. Empty fields don't narrow anything
+type Scope struct {
+	SupertypeIDs []string `json:"supertypeIDs,omitempty"`
+	UserTags     []string `json:"userTags,omitempty"`
+	Producers    []string `json:"producers,omitempty"`
+}
+
+// NormalizeScope trims and deduplicates a scope's lists, rejecting scopes that set both users and tags or exceed the limits
+func NormalizeScope(scope Scope) (Scope, error) {
+	scope.SupertypeIDs = normalizeList(scope.SupertypeIDs)
+	scope.UserTags = normalizeList(scope.UserTags)
+	scope.Producers = normalizeList(scope.Producers)
+
+	if len(scope.SupertypeIDs) > 0 && len(scope.UserTags) > 0 {
+		return Scope{}, ErrInvalidScope
+	}
+	if len(scope.SupertypeIDs) > MaxScopeSupertypeIDs || len(scope.UserTags) > MaxScopeUserTags || len(scope.Producers) > MaxScopeProducers {
+		return Scope{}, ErrInvalidScope
+	}
+	for _, tag := range scope.UserTags {
+		if len(tag) > MaxUserTagLength {
+			return Scope{}, ErrInvalidScope
+		}
+	}
+
+	return scope, nil
+}
+
+// NormalizeUserTags trims and deduplicates the tags a vendor gives a user, rejecting too many or too long tags
+func NormalizeUserTags(tags []string) ([]string, error) {
+	tags = normalizeList(tags)
+	if len(tags) > MaxUserTags {
+		return nil, ErrInvalidUserTags
+	}
+	for _, tag := range tags {
+		if len(tag) > MaxUserTagLength {
+			return nil, ErrInvalidUserTags
+		}
+	}
+	return tags, nil
+}
+
+// FiltersUserTags reports whether admitting an observation depends on its user's tags
+func (s Scope) FiltersUserTags() bool {
+	return len(s.UserTags) > 0
+}
+
+// Admits reports whether an observation of a user, produced by a vendor, is in scope
+// userTags are the tags the subscription's vendor gave the user, and are only read if the scope filters on them
+func (s Scope) Admits(supertypeID string, producer string, userTags []string) bool {
+	if len(s.Producers) > 0 && !contains(s.Producers, producer) {
+		return false
+	}
+	if len(s.SupertypeIDs) > 0 && !contains(s.SupertypeIDs, supertypeID) {
+		return false
+	}
+	if len(s.UserTags) > 0 {
+		for _, tag := range userTags {
+			if contains(s.UserTags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// normalizeList trims every entry of a list, dropping empty and repeated entries and sorting the rest
+func normalizeList(list []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		normalized = append(normalized, entry)
+	}
+	sort.Strings(normalized)
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+func contains(list []string, entry string) bool {
+	for _, e := range list {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
@@ -1,40 +1,493 @@
 package dashboard
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/keys"
+	"github.com/super-type/supertype/internal/netguard"
+)
+
 // Repository provides access to relevant storage
 type repository interface {
-	ListAttributes() ([]string, error)
-	RegisterWebhook(WebhookRequest, string) error
+	RegisterWebhook(WebhookRequest, string) (*WebhookSecret, error)
+	RotateWebhookSecret(RotateSecretRequest, string) (*WebhookSecret, error)
+	GetVendorUsername(string) (*string, error)
+	GetWebhookSubscription(string) (*Subscription, error)
+	ListSubscriptions() ([]Subscription, error)
+	UpdateSubscriptionStatus(string, string) error
+	ListVendorSubscriptions(string) ([]Subscription, error)
+	UpdateSubscription(SubscriptionUpdate) (*Subscription, error)
+	UnregisterWebhook(string) error
+	SetSubscriptionCredentials(string, string, string) (*Subscription, error)
+	SetUserTags(string, string, []string) error
+}
+
+// Verifier checks that a vendor controls a webhook endpoint, and sends it test events
+type verifier interface {
+	Challenge(Subscription) error
+	TestFire(Subscription, string) (*TestDeliveryResult, error)
 }
 
 // Service provides dashboard operations
 type Service interface {
-	ListAttributes() ([]string, error)
-	RegisterWebhook(WebhookRequest, string) error
+	RegisterWebhook(WebhookRequest, string) (*WebhookSecret, error)
+	RotateWebhookSecret(RotateSecretRequest, string) (*WebhookSecret, error)
+	VerifyWebhook(VerifyRequest, string) (*VerificationResult, error)
+	ReverifyWebhooks() error
+	ListWebhooks(string) ([]Webhook, error)
+	PauseWebhook(WebhookRequest, string) (*Webhook, error)
+	ResumeWebhook(WebhookRequest, string) (*VerificationResult, error)
+	UpdateWebhook(UpdateWebhookRequest, string) (*VerificationResult, error)
+	UnregisterWebhook(WebhookRequest, string) error
+	SetWebhookCredentials(CredentialsRequest, string) (*Webhook, error)
+	TestWebhook(TestWebhookRequest, string) (*TestDeliveryResult, error)
+	TagUser(UserTagsRequest, string) error
+}
+
+// Catalog knows which attributes exist and which vendors can see them
+type catalog interface {
+	ValidatePattern(string, string) error
 }
 
 type service struct {
 	r repository
+	v verifier
+	c catalog
 }
 
 // NewService creates a dashboard service with the necessary dependencies
-func NewService(r repository) Service {
-	return &service{r}
+func NewService(r repository, v verifier, c catalog) Service {
+	return &service{r, v, c}
 }
 
-// ListAttributes lists all observations in the Supertype ecosystem
-func (s *service) ListAttributes() ([]string, error) {
-	res, err := s.r.ListAttributes()
+// RegisterWebhook creates a new webhook on a vendor's request
+// The subscription only becomes active once its endpoint answers a verification challenge
+func (s *service) RegisterWebhook(webhookRequest WebhookRequest, apiKey string) (*WebhookSecret, error) {
+	err := netguard.ValidateURL(webhookRequest.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhookRequest.Attribute == "" {
+		webhookRequest.Attribute, err = AttributeFromEndpoint(webhookRequest.Endpoint)
+	} else {
+		webhookRequest.Attribute, err = NormalizeAttributePattern(webhookRequest.Attribute)
+	}
+	if err != nil {
+		return nil, err
+	}
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	err = s.c.ValidatePattern(webhookRequest.Attribute, *username)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := DeliveryMode{}
+	if webhookRequest.Delivery != nil {
+		mode = *webhookRequest.Delivery
+	}
+	mode, err = NormalizeDeliveryMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	webhookRequest.Delivery = &mode
+
+	scope := Scope{}
+	if webhookRequest.Scope != nil {
+		scope, err = NormalizeScope(*webhookRequest.Scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+	webhookRequest.Scope = &scope
+
+	webhookRequest.SchemaVersion, err = NormalizeSchemaVersion(webhookRequest.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Credentials are sealed before registering, so a bad key doesn't leave a subscription behind without them
+	sealed, credentialsType, err := sealCredentials(webhookRequest.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.r.RegisterWebhook(webhookRequest, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.r.GetWebhookSubscription(res.Endpoint)
 	if err != nil {
 		return nil, err
 	}
+	if subscription == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	if sealed != "" {
+		subscription, err = s.r.SetSubscriptionCredentials(res.Endpoint, sealed, credentialsType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := s.verify(*subscription, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	res.Status = result.Status
+	res.VerificationError = result.VerificationError
+
 	return res, nil
 }
 
-// RegisterWebhook creates a new webhook on a vendor's request
-func (s *service) RegisterWebhook(webhookRequest WebhookRequest, apiKey string) error {
-	err := s.r.RegisterWebhook(webhookRequest, apiKey)
+// RotateWebhookSecret issues a new signing secret for a webhook, keeping the old one valid during the overlap
+func (s *service) RotateWebhookSecret(rotateRequest RotateSecretRequest, apiKey string) (*WebhookSecret, error) {
+	res, err := s.r.RotateWebhookSecret(rotateRequest, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// VerifyWebhook challenges one of the vendor's endpoints again, activating its subscription if it passes
+func (s *service) VerifyWebhook(verifyRequest VerifyRequest, apiKey string) (*VerificationResult, error) {
+	subscription, err := s.owned(verifyRequest.Endpoint, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status == StatusPaused || subscription.Status == StatusDisabled {
+		return nil, ErrSubscriptionPaused
+	}
+
+	// Subscriptions that were never verified stay pending, ones that lost verification stay unverified
+	failedStatus := StatusPending
+	if subscription.Status == StatusActive || subscription.Status == StatusUnverified {
+		failedStatus = StatusUnverified
+	}
+
+	result, err := s.verify(*subscription, failedStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ReverifyWebhooks challenges every active subscription again, so endpoints that change hands stop receiving data
+// Subscriptions that fail are marked unverified, and are left alone until their vendor verifies them again
+func (s *service) ReverifyWebhooks() error {
+	subscriptions, err := s.r.ListSubscriptions()
 	if err != nil {
 		return err
 	}
+
+	// One subscription failing to update shouldn't leave the rest unchecked until the next run
+	failed := 0
+	var firstErr error
+	for _, subscription := range subscriptions {
+		if subscription.Status != StatusActive {
+			continue
+		}
+
+		result, err := s.verify(subscription, StatusUnverified)
+		if err != nil {
+			color.Red("Failed to re-verify webhook %v: %v", subscription.Endpoint, err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if result.VerificationError != "" {
+			color.Yellow("Webhook %v failed re-verification: %v", subscription.Endpoint, result.VerificationError)
+		}
+		if result.Status != subscription.Status {
+			color.Cyan("Webhook %v is now %v", subscription.Endpoint, result.Status)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d failed, the first with: %v", ErrReverificationIncomplete, failed, firstErr)
+	}
 	return nil
 }
+
+// ListWebhooks lists every webhook the vendor has registered
+func (s *service) ListWebhooks(apiKey string) ([]Webhook, error) {
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.r.ListVendorSubscriptions(*username)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []Webhook{}
+	for _, subscription := range subscriptions {
+		webhooks = append(webhooks, subscription.Webhook())
+	}
+
+	return webhooks, nil
+}
+
+// PauseWebhook stops deliveries to one of the vendor's webhooks until it's resumed
+func (s *service) PauseWebhook(webhookRequest WebhookRequest, apiKey string) (*Webhook, error) {
+	subscription, err := s.owned(webhookRequest.Endpoint, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.r.UpdateSubscriptionStatus(subscription.Endpoint, StatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Status = StatusPaused
+
+	webhook := subscription.Webhook()
+	return &webhook, nil
+}
+
+// ResumeWebhook challenges a paused or disabled webhook's endpoint, restarting deliveries if it passes
+func (s *service) ResumeWebhook(webhookRequest WebhookRequest, apiKey string) (*VerificationResult, error) {
+	subscription, err := s.owned(webhookRequest.Endpoint, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status != StatusPaused && subscription.Status != StatusDisabled {
+		return nil, ErrSubscriptionNotPaused
+	}
+
+	// The endpoint may have changed hands while stopped, so it has to pass a challenge again
+	failedStatus := StatusPending
+	if subscription.VerifiedAt != "" {
+		failedStatus = StatusUnverified
+	}
+
+	result, err := s.verify(*subscription, failedStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UpdateWebhook moves one of the vendor's webhooks to a new endpoint, attribute pattern, delivery mode, scope or schema version,
+// keeping its secret
+// A new endpoint receives nothing until it answers a verification challenge
+func (s *service) UpdateWebhook(updateRequest UpdateWebhookRequest, apiKey string) (*VerificationResult, error) {
+	subscription, err := s.owned(updateRequest.Endpoint, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := subscription.Endpoint
+	if updateRequest.NewEndpoint != "" {
+		err = netguard.ValidateURL(updateRequest.NewEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		endpoint = updateRequest.NewEndpoint
+	}
+	pattern := subscription.AttributePattern
+	if updateRequest.Attribute != "" {
+		pattern, err = NormalizeAttributePattern(updateRequest.Attribute)
+		if err != nil {
+			return nil, err
+		}
+		err = s.c.ValidatePattern(pattern, subscription.Vendor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mode := subscription.Delivery
+	if updateRequest.Delivery != nil {
+		mode, err = NormalizeDeliveryMode(*updateRequest.Delivery)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scope := subscription.Scope
+	if updateRequest.Scope != nil {
+		scope, err = NormalizeScope(*updateRequest.Scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Changing the schema version is how vendors upgrade, once their receiver understands the new events
+	version := subscription.EventSchemaVersion()
+	if updateRequest.SchemaVersion != 0 {
+		version, err = NormalizeSchemaVersion(updateRequest.SchemaVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.r.UpdateSubscription(SubscriptionUpdate{
+		Endpoint:         subscription.Endpoint,
+		NewEndpoint:      endpoint,
+		AttributePattern: pattern,
+		Delivery:         mode,
+		Scope:            scope,
+		SchemaVersion:    version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated.Endpoint == subscription.Endpoint {
+		return &VerificationResult{Endpoint: updated.Endpoint, Status: updated.Status}, nil
+	}
+
+	result, err := s.verify(*updated, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// UnregisterWebhook removes one of the vendor's webhooks entirely
+func (s *service) UnregisterWebhook(webhookRequest WebhookRequest, apiKey string) error {
+	subscription, err := s.owned(webhookRequest.Endpoint, apiKey)
+	if err != nil {
+		return err
+	}
+
+	return s.r.UnregisterWebhook(subscription.Endpoint)
+}
+
+// SetWebhookCredentials replaces the credentials sent with one of the vendor's webhook deliveries, or removes them
+func (s *service) SetWebhookCredentials(credentialsRequest CredentialsRequest, apiKey string) (*Webhook, error) {
+	subscription, err := s.owned(credentialsRequest.Endpoint, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, credentialsType, err := sealCredentials(credentialsRequest.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.r.SetSubscriptionCredentials(subscription.Endpoint, sealed, credentialsType)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := updated.Webhook()
+	return &webhook, nil
+}
+
+// TestWebhook sends a signed synthetic event to one of the vendor's webhooks and reports how it responded
+// The response is echoed back, so only endpoints that have proven they're the vendor's by passing a challenge can be tested
+func (s *service) TestWebhook(testRequest TestWebhookRequest, apiKey string) (*TestDeliveryResult, error) {
+	subscription, err := s.owned(testRequest.Endpoint, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.VerifiedAt == "" && subscription.Status != StatusActive {
+		return nil, ErrSubscriptionNotVerified
+	}
+
+	attribute := ExampleAttribute(subscription.AttributePattern)
+	if testRequest.Attribute != "" {
+		attribute = strings.Trim(testRequest.Attribute, "/")
+		if strings.ContainsAny(attribute, SingleLevelWildcard+MultiLevelWildcard) || !PatternMatches(subscription.AttributePattern, attribute) {
+			return nil, ErrAttributeNotSubscribed
+		}
+	}
+
+	return s.v.TestFire(*subscription, attribute)
+}
+
+// TagUser replaces the tags the vendor gave one of their users, which webhook scopes can filter on
+func (s *service) TagUser(tagsRequest UserTagsRequest, apiKey string) error {
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return err
+	}
+
+	tags, err := NormalizeUserTags(tagsRequest.Tags)
+	if err != nil {
+		return err
+	}
+
+	return s.r.SetUserTags(*username, tagsRequest.SupertypeID, tags)
+}
+
+// owned returns the subscription for an endpoint, provided it belongs to the vendor with the given API key
+func (s *service) owned(endpoint string, apiKey string) (*Subscription, error) {
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.r.GetWebhookSubscription(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	if subscription.Vendor != *username {
+		return nil, ErrSubscriptionNotOwned
+	}
+
+	return subscription, nil
+}
+
+// verify challenges a subscription's endpoint and records the outcome, moving the subscription to failedStatus if it doesn't pass
+func (s *service) verify(subscription Subscription, failedStatus string) (*VerificationResult, error) {
+	result := VerificationResult{
+		Endpoint: subscription.Endpoint,
+		Status:   StatusActive,
+	}
+
+	err := s.v.Challenge(subscription)
+	if err != nil {
+		result.Status = failedStatus
+		result.VerificationError = err.Error()
+	}
+
+	err = s.r.UpdateSubscriptionStatus(subscription.Endpoint, result.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// sealCredentials validates credentials and encrypts them for storage, returning their description alongside
+// Missing or empty credentials seal to nothing, which clears them
+func sealCredentials(credentials *Credentials) (string, string, error) {
+	if credentials == nil || credentials.Description() == "" {
+		return "", "", nil
+	}
+
+	err := credentials.Validate()
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := keys.Seal(plaintext)
+	if err != nil {
+		color.Red("Failed to seal webhook credentials")
+		return "", "", err
+	}
+
+	return sealed, credentials.Description(), nil
+}
@@ -0,0 +1,88 @@
+package dashboard
+
+import "time"
+
+// Subscription statuses, only active subscriptions receive deliveries
+const (
+	StatusPending    = "pending"
+	StatusActive     = "active"
+	StatusUnverified = "unverified"
+	StatusPaused     = "paused"
+	StatusDisabled   = "disabled"
+)
+
+// ReverificationInterval is how often active subscriptions must prove they still own their endpoint
+const ReverificationInterval = 24 * time.Hour
+
+// DefaultSecretOverlap is how long a rotated-out webhook secret keeps signing deliveries
+const DefaultSecretOverlap = 24 * time.Hour
+
+// Subscription defines a vendor's webhook subscription to an attribute pattern and the secrets used to sign its deliveries
+// Each endpoint has at most one subscription
+type Subscription struct {
+	ID                      string       `json:"id"`
+	Vendor                  string       `json:"vendor"`
+	AttributePattern        string       `json:"attributePattern"`
+	Endpoint                string       `json:"endpoint"`
+	Delivery                DeliveryMode `json:"delivery"`
+	Scope                   Scope        `json:"scope"`
+	SchemaVersion           int          `json:"schemaVersion"`
+	Secret                  string       `json:"secret"`
+	PreviousSecret          string       `json:"previousSecret"`
+	PreviousSecretExpiresAt int64        `json:"previousSecretExpiresAt"`
+	Status                  string       `json:"status"`
+	VerifiedAt              string       `json:"verifiedAt"`
+	CreatedAt               string       `json:"createdAt"`
+	CredentialsType         string       `json:"credentialsType,omitempty"`
+	SealedCredentials       string       `json:"sealedCredentials,omitempty"`
+}
+
+// SubscriptionUpdate is the full new configuration of the subscription at Endpoint, which keeps its secrets
+// Moving it to a different NewEndpoint resets it to pending, since the new endpoint hasn't been verified
+type SubscriptionUpdate struct {
+	Endpoint         string
+	NewEndpoint      string
+	AttributePattern string
+	Delivery         DeliveryMode
+	Scope            Scope
+	SchemaVersion    int
+}
+
+// Active reports whether the subscription should receive deliveries
+func (s Subscription) Active() bool {
+	return s.Status == StatusActive
+}
+
+// EventSchemaVersion returns the version of the events the subscription receives
+// Subscriptions from before versioning have no version, and keep receiving the legacy body
+func (s Subscription) EventSchemaVersion() int {
+	if s.SchemaVersion == 0 {
+		return SchemaVersionLegacy
+	}
+	return s.SchemaVersion
+}
+
+// Webhook describes a subscription to the vendor that owns it, leaving out its secrets
+func (s Subscription) Webhook() Webhook {
+	return Webhook{
+		ID:               s.ID,
+		AttributePattern: s.AttributePattern,
+		Endpoint:         s.Endpoint,
+		Delivery:         s.Delivery,
+		Scope:            s.Scope,
+		SchemaVersion:    s.EventSchemaVersion(),
+		Status:           s.Status,
+		VerifiedAt:       s.VerifiedAt,
+		CreatedAt:        s.CreatedAt,
+		Credentials:      s.CredentialsType,
+	}
+}
+
+// SigningSecrets returns every secret a delivery should currently be signed with, newest first
+func (s Subscription) SigningSecrets(now time.Time) []string {
+	secrets := []string{s.Secret}
+	if s.PreviousSecret != "" && now.Unix() < s.PreviousSecretExpiresAt {
+		secrets = append(secrets, s.PreviousSecret)
+	}
+	return secrets
+}
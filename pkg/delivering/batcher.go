@@ -0,0 +1,113 @@
+package delivering
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// batcher collects deliveries for endpoints that want them batched, handing each batch on as one delivery
+// once it's full or its first delivery has waited long enough
+type batcher struct {
+	mu      sync.Mutex
+	batches map[string]*batch
+	flush   func(Delivery)
+}
+
+// batch is the deliveries waiting to go to one endpoint together
+type batch struct {
+	deliveries []Delivery
+	timer      *time.Timer
+}
+
+// batchBody is what a batched delivery POSTs, the bodies of its deliveries in the order they were produced
+type batchBody struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+func newBatcher(flush func(Delivery)) *batcher {
+	return &batcher{
+		batches: map[string]*batch{},
+		flush:   flush,
+	}
+}
+
+// add puts a delivery in its endpoint's batch, flushing the batch if that fills it
+func (b *batcher) add(delivery Delivery) {
+	b.mu.Lock()
+	current := b.batches[delivery.Endpoint]
+	if current == nil {
+		current = &batch{}
+		b.batches[delivery.Endpoint] = current
+		wait := time.Duration(delivery.Mode.MaxWaitMs) * time.Millisecond
+		current.timer = time.AfterFunc(wait, func() {
+			b.expire(delivery.Endpoint, current)
+		})
+	}
+	current.deliveries = append(current.deliveries, delivery)
+
+	if len(current.deliveries) < delivery.Mode.MaxEvents {
+		b.mu.Unlock()
+		return
+	}
+	current.timer.Stop()
+	delete(b.batches, delivery.Endpoint)
+	b.mu.Unlock()
+
+	b.flush(combine(current.deliveries))
+}
+
+// expire flushes a batch whose wait is over, unless it was already flushed for being full
+func (b *batcher) expire(endpoint string, expired *batch) {
+	b.mu.Lock()
+	if b.batches[endpoint] != expired {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.batches, endpoint)
+	b.mu.Unlock()
+
+	b.flush(combine(expired.deliveries))
+}
+
+// drain removes every waiting batch without flushing it, for when the dispatcher stops
+func (b *batcher) drain() []Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var drained []Delivery
+	for endpoint, waiting := range b.batches {
+		waiting.timer.Stop()
+		delete(b.batches, endpoint)
+		drained = append(drained, combine(waiting.deliveries))
+	}
+	return drained
+}
+
+// combine turns a batch into a single delivery carrying all of its observations
+func combine(deliveries []Delivery) Delivery {
+	first := deliveries[0]
+	combined := Delivery{
+		ID:             uuid.New().String(),
+		SubscriptionID: first.SubscriptionID,
+		Endpoint:       first.Endpoint,
+		Vendor:         first.Vendor,
+		CreatedAt:      first.CreatedAt,
+	}
+
+	body := batchBody{}
+	for _, delivery := range deliveries {
+		body.Events = append(body.Events, json.RawMessage(delivery.Body))
+		combined.Events = append(combined.Events, Event{
+			SupertypeID: delivery.SupertypeID,
+			Attribute:   delivery.Attribute,
+			Sequence:    delivery.Sequence,
+		})
+	}
+	// The bodies are already JSON, so this can't fail
+	combined.Body, _ = json.Marshal(body)
+
+	return combined
+}
@@ -0,0 +1,94 @@
+package delivering
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker keeps a circuit per endpoint, so an endpoint that keeps failing stops tying up workers
+// A circuit opens after enough consecutive failures. While it's open, deliveries to the endpoint wait,
+// and one is let through every probe interval to check whether the endpoint has recovered
+type breaker struct {
+	mu            sync.Mutex
+	circuits      map[string]*circuit
+	threshold     int
+	probeInterval time.Duration
+	disableAfter  time.Duration
+}
+
+// circuit is the failure history of one endpoint since it last succeeded
+type circuit struct {
+	failures     int
+	failingSince time.Time
+	open         bool
+	nextProbe    time.Time
+}
+
+func newBreaker(config Config) *breaker {
+	return &breaker{
+		circuits:      map[string]*circuit{},
+		threshold:     config.BreakerThreshold,
+		probeInterval: config.ProbeInterval,
+		disableAfter:  config.DisableAfter,
+	}
+}
+
+// allow reports whether a delivery to the endpoint may be sent now, and if not, how long until it may
+// Letting a delivery through an open circuit makes it the probe, so others keep waiting until the next interval
+func (b *breaker) allow(endpoint string, now time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[endpoint]
+	if c == nil || !c.open {
+		return 0, true
+	}
+	if now.Before(c.nextProbe) {
+		return c.nextProbe.Sub(now), false
+	}
+
+	c.nextProbe = now.Add(b.probeInterval)
+	return 0, true
+}
+
+// success closes the endpoint's circuit and forgets its failures
+func (b *breaker) success(endpoint string) {
+	b.forget(endpoint)
+}
+
+// forget drops the endpoint's circuit, e.g. once it's disabled, so a resumed subscription starts afresh
+func (b *breaker) forget(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.circuits, endpoint)
+}
+
+// failure counts a failed delivery to the endpoint
+// It reports whether this failure opened the circuit, and whether the endpoint has now been failing long enough to disable
+func (b *breaker) failure(endpoint string, now time.Time) (bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[endpoint]
+	if c == nil {
+		c = &circuit{failingSince: now}
+		b.circuits[endpoint] = c
+	}
+	c.failures++
+
+	opened := false
+	if !c.open && c.failures >= b.threshold {
+		c.open = true
+		opened = true
+	}
+	if c.open {
+		c.nextProbe = now.Add(b.probeInterval)
+	}
+
+	// The circuit is kept until the endpoint is disabled, so a failed attempt to disable it is tried again on the next failure
+	if c.open && b.disableAfter > 0 && now.Sub(c.failingSince) >= b.disableAfter {
+		return opened, true
+	}
+
+	return opened, false
+}
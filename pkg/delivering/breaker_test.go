@@ -0,0 +1,144 @@
+package delivering
+
+import (
+	"testing"
+	"time"
+)
+
+func testBreaker() *breaker {
+	return newBreaker(Config{
+		BreakerThreshold: 3,
+		ProbeInterval:    time.Minute,
+		DisableAfter:     time.Hour,
+	})
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := testBreaker()
+	now := time.Now()
+
+	for i := 1; i <= 3; i++ {
+		if _, ok := b.allow("e", now); !ok {
+			t.Fatalf("delivery held back after %d failures, before the circuit opened", i-1)
+		}
+		opened, disable := b.failure("e", now)
+		if opened != (i == 3) || disable {
+			t.Fatalf("failure %d = opened %v, disable %v", i, opened, disable)
+		}
+	}
+
+	wait, ok := b.allow("e", now)
+	if ok || wait != time.Minute {
+		t.Fatalf("allow on open circuit = %v, %v, want held back for a minute", wait, ok)
+	}
+
+	// Other endpoints have their own circuits
+	if _, ok := b.allow("other", now); !ok {
+		t.Fatal("open circuit held back another endpoint")
+	}
+}
+
+func TestBreakerProbes(t *testing.T) {
+	b := testBreaker()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		b.failure("e", now)
+	}
+
+	probeAt := now.Add(time.Minute)
+	if _, ok := b.allow("e", probeAt); !ok {
+		t.Fatal("no probe let through once the probe interval passed")
+	}
+	if _, ok := b.allow("e", probeAt); ok {
+		t.Fatal("second delivery let through alongside the probe")
+	}
+
+	b.success("e")
+	if _, ok := b.allow("e", probeAt); !ok {
+		t.Fatal("circuit still open after the probe succeeded")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := testBreaker()
+	now := time.Now()
+
+	// Failures separated by a success aren't consecutive, so never open the circuit
+	for i := 0; i < 5; i++ {
+		b.failure("e", now)
+		b.failure("e", now)
+		b.success("e")
+	}
+	if _, ok := b.allow("e", now); !ok {
+		t.Fatal("circuit opened by failures that weren't consecutive")
+	}
+
+	// Nor do they count towards how long the endpoint has been failing
+	b.failure("e", now)
+	b.success("e")
+	later := now.Add(2 * time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, disable := b.failure("e", later); disable {
+			t.Fatal("endpoint disabled for failing since before it last succeeded")
+		}
+	}
+}
+
+func TestBreakerDisables(t *testing.T) {
+	b := testBreaker()
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		b.failure("e", now)
+	}
+
+	if _, disable := b.failure("e", now.Add(59*time.Minute)); disable {
+		t.Fatal("endpoint disabled before failing for DisableAfter")
+	}
+	if _, disable := b.failure("e", now.Add(time.Hour)); !disable {
+		t.Fatal("endpoint not disabled after failing for DisableAfter")
+	}
+
+	// Until the endpoint is forgotten, e.g. because its subscription couldn't be disabled, later failures disable it again
+	if _, disable := b.failure("e", now.Add(2*time.Hour)); !disable {
+		t.Fatal("endpoint not disabled again before its circuit was forgotten")
+	}
+
+	// Disabling forgets the circuit, so a resumed subscription starts afresh
+	b.forget("e")
+	if _, ok := b.allow("e", now.Add(2*time.Hour)); !ok {
+		t.Fatal("circuit kept after forgetting it")
+	}
+}
+
+func TestBreakerNeverDisablesWithoutDisableAfter(t *testing.T) {
+	b := newBreaker(Config{BreakerThreshold: 1, ProbeInterval: time.Minute})
+	now := time.Now()
+
+	b.failure("e", now)
+	if _, disable := b.failure("e", now.Add(1000*time.Hour)); disable {
+		t.Fatal("endpoint disabled with DisableAfter unset")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       bool
+	}{
+		{0, true},
+		{400, false},
+		{401, false},
+		{404, false},
+		{408, true},
+		{410, false},
+		{429, true},
+		{500, true},
+		{503, true},
+	}
+
+	for _, tt := range tests {
+		if got := retryable(tt.statusCode); got != tt.want {
+			t.Errorf("retryable(%d) = %v, want %v", tt.statusCode, got, tt.want)
+		}
+	}
+}
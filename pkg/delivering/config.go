@@ -0,0 +1,44 @@
+package delivering
+
+import "time"
+
+// Config tunes how the dispatcher sends webhooks
+type Config struct {
+	// Workers is how many deliveries are sent concurrently
+	Workers int
+	// QueueSize is how many deliveries can wait for a worker before Enqueue fails
+	QueueSize int
+	// LaneSize is how many deliveries can wait behind the one in flight for an ordering key before the newest are
+	// dead-lettered, zero never caps them
+	LaneSize int
+	// Timeout bounds each POST to an endpoint
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it's dead-lettered
+	MaxAttempts int
+	// BaseBackoff is the wait before the first retry, doubling after each attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the wait between attempts, including waits requested through Retry-After
+	MaxBackoff time.Duration
+	// BreakerThreshold is how many consecutive failures open an endpoint's circuit
+	BreakerThreshold int
+	// ProbeInterval is how often a delivery is let through an open circuit to check whether the endpoint recovered
+	ProbeInterval time.Duration
+	// DisableAfter is how long an endpoint may keep failing before its subscription is disabled, zero never disables
+	DisableAfter time.Duration
+}
+
+// DefaultConfig returns the configuration used in production
+func DefaultConfig() Config {
+	return Config{
+		Workers:          16,
+		QueueSize:        1024,
+		LaneSize:         256,
+		Timeout:          10 * time.Second,
+		MaxAttempts:      8,
+		BaseBackoff:      time.Second,
+		MaxBackoff:       time.Hour,
+		BreakerThreshold: 5,
+		ProbeInterval:    time.Minute,
+		DisableAfter:     72 * time.Hour,
+	}
+}
@@ -0,0 +1,35 @@
+package delivering
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/super-type/supertype/internal/keys"
+	"github.com/super-type/supertype/pkg/dashboard"
+)
+
+// applyCredentials decrypts a subscription's outbound credentials and adds their headers to a request
+// Call it before setting our own headers, so ours always win
+func applyCredentials(req *http.Request, subscription dashboard.Subscription) error {
+	if subscription.SealedCredentials == "" {
+		return nil
+	}
+
+	plaintext, err := keys.Open(subscription.SealedCredentials)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCredentialsUnavailable, err)
+	}
+
+	var credentials dashboard.Credentials
+	err = json.Unmarshal(plaintext, &credentials)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCredentialsUnavailable, err)
+	}
+
+	for name, value := range credentials.HTTPHeaders() {
+		req.Header.Set(name, value)
+	}
+
+	return nil
+}
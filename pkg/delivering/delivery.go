@@ -0,0 +1,107 @@
+package delivering
+
+import (
+	"time"
+
+	"github.com/super-type/supertype/pkg/dashboard"
+)
+
+// TimeFormat is the layout of every time we store for deliveries, in UTC so they sort and compare as strings
+const TimeFormat = "2006-01-02T15:04:05Z"
+
+// Delivery is a webhook POST waiting to be sent to a subscribed endpoint
+// A batch is a single delivery carrying several observations, listed in Events.
+// PriorAttempts counts the attempts made before the delivery was replayed, which its attempt numbers carry on from
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	Endpoint       string
+	Vendor         string
+	SupertypeID    string
+	Attribute      string
+	Sequence       int64
+	Body           []byte
+	Attempt        int
+	PriorAttempts  int
+	CreatedAt      time.Time
+	Mode           dashboard.DeliveryMode
+	Events         []Event
+}
+
+// Event identifies one of the observations in a batched delivery
+type Event struct {
+	SupertypeID string `json:"supertypeID"`
+	Attribute   string `json:"attribute"`
+	Sequence    int64  `json:"sequence"`
+}
+
+// orderingKey identifies the deliveries that must reach an endpoint in order, those of one user's attribute
+func (d *Delivery) orderingKey() string {
+	return d.Endpoint + "\x00" + d.SupertypeID + "\x00" + d.Attribute
+}
+
+// DeadLetter is a delivery we gave up on after running out of attempts
+type DeadLetter struct {
+	ID             string  `json:"id"`
+	SubscriptionID string  `json:"subscriptionID"`
+	Endpoint       string  `json:"endpoint"`
+	Vendor         string  `json:"vendor"`
+	SupertypeID    string  `json:"supertypeID"`
+	Attribute      string  `json:"attribute"`
+	Sequence       int64   `json:"sequence"`
+	Events         []Event `json:"events,omitempty"`
+	Body           string  `json:"body"`
+	Attempts       int     `json:"attempts"`
+	LastStatusCode int     `json:"lastStatusCode"`
+	LastError      string  `json:"lastError"`
+	CreatedAt      string  `json:"createdAt"`
+	FailedAt       string  `json:"failedAt"`
+}
+
+// attemptsSinceReplay counts the attempts made since the delivery was queued, or last replayed
+func (d *Delivery) attemptsSinceReplay() int {
+	return d.Attempt - d.PriorAttempts
+}
+
+// Attempt is one try at sending a delivery, kept so vendors can see what happened to their webhooks
+type Attempt struct {
+	DeliveryID  string `json:"deliveryID"`
+	Attempt     int    `json:"attempt"`
+	Endpoint    string `json:"endpoint"`
+	Vendor      string `json:"vendor"`
+	SupertypeID string `json:"supertypeID"`
+	Attribute   string `json:"attribute"`
+	Sequence    int64  `json:"sequence"`
+	CreatedAt   string `json:"createdAt"`
+	Succeeded   bool   `json:"succeeded"`
+	StatusCode  int    `json:"statusCode"`
+	LatencyMs   int64  `json:"latencyMs"`
+	Error       string `json:"error,omitempty"`
+	Time        string `json:"time"`
+}
+
+// DeliveryReport is everything we know about a single delivery
+type DeliveryReport struct {
+	DeliveryID string      `json:"deliveryID"`
+	Attempts   []Attempt   `json:"attempts"`
+	DeadLetter *DeadLetter `json:"deadLetter,omitempty"`
+}
+
+// Filter narrows down which attempts or dead letters are returned, empty fields match everything
+type Filter struct {
+	Endpoint string
+	Since    string
+	Until    string
+}
+
+// ReplayRequest selects dead-lettered deliveries to send again, either by ID or by when they failed
+type ReplayRequest struct {
+	IDs   []string `json:"ids"`
+	Since string   `json:"since"`
+	Until string   `json:"until"`
+}
+
+// ReplayResult says which deliveries were queued again
+type ReplayResult struct {
+	Replayed []string `json:"replayed"`
+}
@@ -0,0 +1,533 @@
+package delivering
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/google/uuid"
+	"github.com/super-type/supertype/internal/netguard"
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/notifying"
+	"github.com/super-type/supertype/pkg/signing"
+)
+
+// dispatchRepository provides access to the storage needed to send deliveries
+type dispatchRepository interface {
+	GetWebhookSubscription(string) (*dashboard.Subscription, error)
+	AppendAccessLog(accesslog.Entry) error
+	PutDeadLetter(DeadLetter) error
+	PutAttempt(Attempt) error
+	UpdateSubscriptionStatus(string, string) error
+	PutNotification(notifying.Notification) error
+}
+
+// Dispatcher sends webhook deliveries in the background with a bounded pool of workers, retrying failures with backoff
+// Deliveries sharing an ordering key are sent one at a time in sequence order, so a retry holds back the ones after it.
+// Ordering is best-effort: it's only kept within one instance and among deliveries waiting at the same time,
+// so a delivery can go out before an earlier one that hadn't been enqueued yet. Receivers reorder by sequence
+type Dispatcher struct {
+	r       dispatchRepository
+	config  Config
+	client  *http.Client
+	breaker *breaker
+	batcher *batcher
+	queue   chan *Delivery
+	stop    chan struct{}
+	stopped sync.Once
+	workers sync.WaitGroup
+
+	// lanes holds the deliveries waiting behind the one in flight for each ordering key, which is present while busy
+	lanesMu sync.Mutex
+	lanes   map[string][]*Delivery
+
+	// retries holds the timers of deliveries waiting to be retried, so Stop can dead-letter them, and is nil once stopped
+	retriesMu sync.Mutex
+	retries   map[*Delivery]*time.Timer
+	retrying  sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher, which sends nothing until Start is called
+func NewDispatcher(r dispatchRepository, config Config) *Dispatcher {
+	d := &Dispatcher{
+		r:       r,
+		config:  config,
+		client:  netguard.NewClient(config.Timeout),
+		breaker: newBreaker(config),
+		queue:   make(chan *Delivery, config.QueueSize),
+		stop:    make(chan struct{}),
+		lanes:   map[string][]*Delivery{},
+		retries: map[*Delivery]*time.Timer{},
+	}
+	d.batcher = newBatcher(func(batch Delivery) {
+		if err := d.enqueue(batch); err != nil {
+			color.Red("Failed to queue webhook batch %v for %v: %v", batch.ID, batch.Endpoint, err)
+		}
+	})
+	return d
+}
+
+// Start launches the dispatcher's workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.config.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+}
+
+// Stop stops accepting deliveries and waits for in-flight ones to finish
+// Deliveries still queued or waiting to be retried are dead-lettered so they can be replayed
+func (d *Dispatcher) Stop() {
+	d.stopped.Do(func() {
+		close(d.stop)
+	})
+	d.workers.Wait()
+
+	// Retries that haven't fired are cancelled, while those firing dead-letter themselves or land in the queue
+	d.retriesMu.Lock()
+	retries := d.retries
+	d.retries = nil
+	d.retriesMu.Unlock()
+	for delivery, timer := range retries {
+		if timer.Stop() {
+			d.retrying.Done()
+			d.deadLetter(delivery, 0, ErrDispatcherStopped)
+			d.done(delivery)
+		}
+	}
+	d.retrying.Wait()
+
+	for _, batch := range d.batcher.drain() {
+		d.deadLetter(&batch, 0, ErrDispatcherStopped)
+	}
+
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.deadLetter(delivery, 0, ErrDispatcherStopped)
+			d.done(delivery)
+		default:
+			return
+		}
+	}
+}
+
+// Enqueue hands a delivery to the dispatcher without waiting for it to be sent
+func (d *Dispatcher) Enqueue(delivery Delivery) error {
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+
+	select {
+	case <-d.stop:
+		return ErrDispatcherStopped
+	default:
+	}
+
+	// Batches are queued when they fill up or their wait is over
+	if delivery.Mode.Batched() {
+		d.batcher.add(delivery)
+		return nil
+	}
+
+	return d.enqueue(delivery)
+}
+
+// DeadLetter stores a delivery that couldn't be queued so its vendor can replay it, without trying to send it
+func (d *Dispatcher) DeadLetter(delivery Delivery, cause error) {
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+	d.deadLetter(&delivery, 0, cause)
+}
+
+// enqueue queues a delivery, or a batch, once the one in flight for the same key is done
+func (d *Dispatcher) enqueue(delivery Delivery) error {
+	key := delivery.orderingKey()
+	d.lanesMu.Lock()
+	if waiting, busy := d.lanes[key]; busy {
+		// Only the newest waiting observation of the user's attribute is worth sending, the rest are dropped
+		if delivery.Mode.Coalesced() {
+			if len(waiting) > 0 && waiting[len(waiting)-1].Sequence > delivery.Sequence {
+				d.lanesMu.Unlock()
+				return nil
+			}
+			waiting = nil
+		}
+		waiting = insertBySequence(waiting, &delivery)
+
+		// A lane stuck behind a failing endpoint gives up its newest deliveries rather than growing without bound
+		var overflow *Delivery
+		if d.config.LaneSize > 0 && len(waiting) > d.config.LaneSize {
+			overflow = waiting[len(waiting)-1]
+			waiting = waiting[:len(waiting)-1]
+		}
+		d.lanes[key] = waiting
+		d.lanesMu.Unlock()
+
+		if overflow != nil {
+			d.deadLetter(overflow, 0, ErrLaneFull)
+			if overflow == &delivery {
+				return ErrLaneFull
+			}
+		}
+		return nil
+	}
+	d.lanes[key] = nil
+	d.lanesMu.Unlock()
+
+	select {
+	case d.queue <- &delivery:
+		return nil
+	default:
+		d.deadLetter(&delivery, 0, ErrQueueFull)
+		d.done(&delivery)
+		return ErrQueueFull
+	}
+}
+
+// done releases a delivery's ordering key once it has been sent or given up on, queueing the next one waiting for it
+func (d *Dispatcher) done(delivery *Delivery) {
+	key := delivery.orderingKey()
+
+	d.lanesMu.Lock()
+	waiting := d.lanes[key]
+	if len(waiting) == 0 {
+		delete(d.lanes, key)
+		d.lanesMu.Unlock()
+		return
+	}
+	next := waiting[0]
+	d.lanes[key] = waiting[1:]
+	d.lanesMu.Unlock()
+
+	// Workers call done, so the next delivery is queued without blocking them
+	d.retry(next, 0)
+}
+
+// insertBySequence adds a delivery to those waiting for a key, keeping them in sequence order
+// Concurrent produces can enqueue a user's observations of an attribute slightly out of order
+func insertBySequence(waiting []*Delivery, delivery *Delivery) []*Delivery {
+	i := sort.Search(len(waiting), func(i int) bool {
+		return waiting[i].Sequence > delivery.Sequence
+	})
+	waiting = append(waiting, nil)
+	copy(waiting[i+1:], waiting[i:])
+	waiting[i] = delivery
+	return waiting
+}
+
+// work sends deliveries from the queue until the dispatcher stops
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case delivery := <-d.queue:
+			d.attempt(delivery)
+		}
+	}
+}
+
+// attempt sends a delivery once, scheduling a retry or dead-lettering it if that fails
+func (d *Dispatcher) attempt(delivery *Delivery) {
+	// Deliveries held back by an open circuit wait for the next probe without using up an attempt
+	if wait, ok := d.breaker.allow(delivery.Endpoint, time.Now()); !ok {
+		d.retry(delivery, wait)
+		return
+	}
+
+	delivery.Attempt++
+
+	start := time.Now()
+	statusCode, retryAfter, err := d.send(delivery)
+	d.recordAttempt(delivery, statusCode, time.Since(start), err)
+	if err == nil {
+		d.breaker.success(delivery.Endpoint)
+		d.recordAccess(delivery)
+		d.done(delivery)
+		return
+	}
+
+	// A disabled subscription can be resumed, so its deliveries are kept for replaying
+	if err == ErrSubscriptionDisabled {
+		d.deadLetter(delivery, 0, err)
+		d.done(delivery)
+		return
+	}
+
+	// The subscription was paused or lost verification after the delivery was queued, so it's dropped
+	if err == ErrSubscriptionInactive {
+		color.Yellow("Dropping webhook delivery %v: %v", delivery.ID, err)
+		d.done(delivery)
+		return
+	}
+
+	color.Red("Webhook delivery %v to %v failed (attempt %d): %v", delivery.ID, delivery.Endpoint, delivery.Attempt, err)
+
+	// A refused address or undecryptable credentials won't be fixed by retrying, and say nothing about whether the endpoint is up
+	if netguard.Unsafe(err) || errors.Is(err, ErrCredentialsUnavailable) {
+		d.deadLetter(delivery, statusCode, err)
+		d.done(delivery)
+		return
+	}
+
+	// Our own failures are retried without counting against the endpoint
+	if errors.Is(err, ErrNotSent) {
+		if delivery.attemptsSinceReplay() >= d.config.MaxAttempts {
+			d.deadLetter(delivery, 0, err)
+			d.done(delivery)
+			return
+		}
+		d.retry(delivery, d.backoff(delivery.attemptsSinceReplay(), 0))
+		return
+	}
+
+	// Only failures that suggest the endpoint is down count towards its circuit,
+	// any other response means it's up, so its failures so far weren't consecutive
+	if !retryable(statusCode) {
+		d.breaker.success(delivery.Endpoint)
+	} else {
+		opened, disable := d.breaker.failure(delivery.Endpoint, time.Now())
+		if opened {
+			color.Yellow("Opened circuit for webhook %v, probing every %v", delivery.Endpoint, d.config.ProbeInterval)
+		}
+		// If the subscription can't be disabled, the delivery carries on as usual and the next failure tries again
+		if disable && d.disable(delivery) == nil {
+			d.deadLetter(delivery, statusCode, ErrSubscriptionDisabled)
+			d.done(delivery)
+			return
+		}
+	}
+
+	if !retryable(statusCode) || delivery.attemptsSinceReplay() >= d.config.MaxAttempts {
+		d.deadLetter(delivery, statusCode, err)
+		d.done(delivery)
+		return
+	}
+
+	d.retry(delivery, d.backoff(delivery.attemptsSinceReplay(), retryAfter))
+}
+
+// send POSTs a delivery to its endpoint, signed with the subscription's current secrets
+// It returns the response status code, if any, and how long the endpoint asked us to wait before retrying
+func (d *Dispatcher) send(delivery *Delivery) (int, time.Duration, error) {
+	subscription, err := d.r.GetWebhookSubscription(delivery.Endpoint)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrNotSent, err)
+	}
+	// The endpoint may have been unregistered and subscribed again since the delivery was queued
+	if subscription == nil || (delivery.SubscriptionID != "" && subscription.ID != delivery.SubscriptionID) {
+		return 0, 0, ErrSubscriptionInactive
+	}
+	if subscription.Status == dashboard.StatusDisabled {
+		return 0, 0, ErrSubscriptionDisabled
+	}
+	if !subscription.Active() {
+		return 0, 0, ErrSubscriptionInactive
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", delivery.Endpoint, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrNotSent, err)
+	}
+	req = req.WithContext(ctx)
+	err = applyCredentials(req, *subscription)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Supertype-Delivery", delivery.ID)
+
+	// Sign with the subscription's own secret(s) so vendors can verify the delivery came from us
+	now := time.Now()
+	req.Header.Set(signing.WebhookSignatureHeader, signing.WebhookSignatureHeaderValue(now.Unix(), delivery.Body, subscription.SigningSecrets(now)...))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, 0, nil
+	}
+
+	return resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("endpoint responded %d", resp.StatusCode)
+}
+
+// retry puts a delivery back on the queue once its backoff has passed, or dead-letters it if the dispatcher has stopped
+func (d *Dispatcher) retry(delivery *Delivery, wait time.Duration) {
+	d.retriesMu.Lock()
+	if d.retries == nil {
+		d.retriesMu.Unlock()
+		d.deadLetter(delivery, 0, ErrDispatcherStopped)
+		d.done(delivery)
+		return
+	}
+	defer d.retriesMu.Unlock()
+
+	d.retrying.Add(1)
+	d.retries[delivery] = time.AfterFunc(wait, func() {
+		defer d.retrying.Done()
+		d.retriesMu.Lock()
+		delete(d.retries, delivery)
+		d.retriesMu.Unlock()
+
+		select {
+		case <-d.stop:
+			d.deadLetter(delivery, 0, ErrDispatcherStopped)
+			d.done(delivery)
+		case d.queue <- delivery:
+		}
+	})
+}
+
+// backoff returns how long to wait before the next attempt, preferring the endpoint's Retry-After
+func (d *Dispatcher) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	wait := retryAfter
+	if wait <= 0 {
+		wait = d.config.BaseBackoff << uint(attempt-1)
+		// Jitter keeps retries to the same endpoint from arriving in lockstep
+		wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+	}
+	if wait <= 0 || wait > d.config.MaxBackoff {
+		wait = d.config.MaxBackoff
+	}
+	return wait
+}
+
+// disable stops deliveries to an endpoint that has been failing for too long and tells its vendor
+// Only failing to store the new status is an error, since the endpoint is disabled whether or not its vendor is told
+func (d *Dispatcher) disable(delivery *Delivery) error {
+	color.Red("Disabling webhook %v after failing for %v", delivery.Endpoint, d.config.DisableAfter)
+
+	err := d.r.UpdateSubscriptionStatus(delivery.Endpoint, dashboard.StatusDisabled)
+	if err != nil {
+		color.Red("Failed to disable webhook %v: %v", delivery.Endpoint, err)
+		return err
+	}
+	d.breaker.forget(delivery.Endpoint)
+
+	err = d.r.PutNotification(notifying.Notification{
+		ID:       uuid.New().String(),
+		Vendor:   delivery.Vendor,
+		Type:     notifying.TypeWebhookDisabled,
+		Endpoint: delivery.Endpoint,
+		Message:  fmt.Sprintf("Webhook %v was disabled after failing for %v. Fix the endpoint, resume it with /resume-webhook, then replay its failed deliveries with /replay-webhooks", delivery.Endpoint, d.config.DisableAfter),
+		Time:     time.Now().UTC().Format(TimeFormat),
+	})
+	if err != nil {
+		color.Red("Failed to notify %v that webhook %v was disabled: %v", delivery.Vendor, delivery.Endpoint, err)
+	}
+
+	return nil
+}
+
+// recordAttempt logs the outcome of one attempt at a delivery
+func (d *Dispatcher) recordAttempt(delivery *Delivery, statusCode int, latency time.Duration, cause error) {
+	attempt := Attempt{
+		DeliveryID:  delivery.ID,
+		Attempt:     delivery.Attempt,
+		Endpoint:    delivery.Endpoint,
+		Vendor:      delivery.Vendor,
+		SupertypeID: delivery.SupertypeID,
+		Attribute:   delivery.Attribute,
+		Sequence:    delivery.Sequence,
+		CreatedAt:   delivery.CreatedAt.UTC().Format(TimeFormat),
+		Succeeded:   cause == nil,
+		StatusCode:  statusCode,
+		LatencyMs:   int64(latency / time.Millisecond),
+		Time:        time.Now().UTC().Format(TimeFormat),
+	}
+	if cause != nil {
+		attempt.Error = cause.Error()
+	}
+
+	err := d.r.PutAttempt(attempt)
+	if err != nil {
+		color.Red("Failed to record webhook attempt %v/%d: %v", delivery.ID, delivery.Attempt, err)
+	}
+}
+
+// recordAccess adds a successful delivery to the access log of the user of each observation it carried
+func (d *Dispatcher) recordAccess(delivery *Delivery) {
+	events := delivery.Events
+	if len(events) == 0 {
+		events = []Event{{SupertypeID: delivery.SupertypeID, Attribute: delivery.Attribute, Sequence: delivery.Sequence}}
+	}
+
+	for _, event := range events {
+		err := d.r.AppendAccessLog(accesslog.Entry{
+			SupertypeID: event.SupertypeID,
+			Action:      accesslog.ActionWebhook,
+			Vendor:      delivery.Vendor,
+			Attribute:   event.Attribute,
+		})
+		if err != nil {
+			color.Red("Failed to record webhook delivery in access log: %v", err)
+		}
+	}
+}
+
+// deadLetter stores a delivery we've given up on
+func (d *Dispatcher) deadLetter(delivery *Delivery, statusCode int, cause error) {
+	err := d.r.PutDeadLetter(DeadLetter{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		Endpoint:       delivery.Endpoint,
+		Vendor:         delivery.Vendor,
+		SupertypeID:    delivery.SupertypeID,
+		Attribute:      delivery.Attribute,
+		Sequence:       delivery.Sequence,
+		Events:         delivery.Events,
+		Body:           string(delivery.Body),
+		Attempts:       delivery.Attempt,
+		LastStatusCode: statusCode,
+		LastError:      cause.Error(),
+		CreatedAt:      delivery.CreatedAt.UTC().Format(TimeFormat),
+		FailedAt:       time.Now().UTC().Format(TimeFormat),
+	})
+	if err != nil {
+		color.Red("Failed to dead-letter webhook delivery %v: %v", delivery.ID, err)
+	}
+}
+
+// retryable reports whether a failed attempt with this status code is worth trying again
+// Network errors (no status code), timeouts, throttling and server errors are, other client errors aren't
+func retryable(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
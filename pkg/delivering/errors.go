@@ -0,0 +1,40 @@
+package delivering
+
+import "errors"
+
+// ErrQueueFull is used when the dispatcher can't accept any more deliveries
+var ErrQueueFull = errors.New("Webhook delivery queue is full")
+
+// ErrLaneFull is used when too many deliveries are waiting behind the one in flight for the same user's attribute and endpoint
+var ErrLaneFull = errors.New("Too many webhook deliveries waiting for the same endpoint")
+
+// ErrScopeUnavailable is used when a delivery is held back because the user tags its scope filters on couldn't be looked up
+var ErrScopeUnavailable = errors.New("Webhook scope could not be checked")
+
+// ErrDispatcherStopped is used when a delivery is enqueued after the dispatcher has stopped
+var ErrDispatcherStopped = errors.New("Webhook dispatcher has stopped")
+
+// ErrDeliveryNotFound is used when a vendor asks about a delivery that isn't theirs or doesn't exist
+var ErrDeliveryNotFound = errors.New("Webhook delivery not found")
+
+// ErrInvalidTimeFilter is used when a delivery time filter isn't RFC 3339
+var ErrInvalidTimeFilter = errors.New("Delivery time filters must be RFC 3339 timestamps")
+
+// ErrEmptyReplay is used when a replay request selects nothing
+var ErrEmptyReplay = errors.New("Replay requests need delivery IDs or a time range")
+
+// ErrChallengeFailed is used when an endpoint doesn't prove it belongs to the vendor registering it
+var ErrChallengeFailed = errors.New("Webhook endpoint failed verification")
+
+// ErrSubscriptionInactive is used when a delivery's subscription is no longer active when it's sent
+var ErrSubscriptionInactive = errors.New("Webhook subscription is not active")
+
+// ErrSubscriptionDisabled is used when a delivery's subscription was disabled after its endpoint kept failing
+var ErrSubscriptionDisabled = errors.New("Webhook subscription was disabled after its endpoint kept failing")
+
+// ErrCredentialsUnavailable is used when a subscription's stored credentials can't be decrypted, so nothing is sent without them
+var ErrCredentialsUnavailable = errors.New("Webhook credentials could not be decrypted")
+
+// ErrNotSent is used when a delivery fails before a request reaches its endpoint, e.g. its subscription couldn't be looked up
+// It says nothing about whether the endpoint is up, so doesn't count towards its circuit
+var ErrNotSent = errors.New("Webhook delivery was not sent")
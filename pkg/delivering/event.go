@@ -0,0 +1,113 @@
+package delivering
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/super-type/supertype/pkg/dashboard"
+)
+
+// ObservationEventType is the type of the events sent when an observation is produced
+const ObservationEventType = "observation.created"
+
+// legacyDateFormat is how the legacy body formats when an observation was added
+const legacyDateFormat = "2006-01-02 15:04:05.000000000"
+
+// ObservationEvent is everything a webhook is told about a produced observation, whichever schema version it receives
+// The same event, with the same ID, is sent to every subscription it matches
+type ObservationEvent struct {
+	ID          string
+	Type        string
+	OccurredAt  time.Time
+	Attribute   string
+	SupertypeID string
+	Producer    string
+	Sequence    int64
+	Ciphertext  string
+	IV          string
+	PublicKey   string
+	Signature   string
+	Timestamp   int64
+}
+
+// Envelope is the body of a schema version 2 event
+type Envelope struct {
+	ID            string             `json:"id"`
+	Type          string             `json:"type"`
+	SchemaVersion int                `json:"schemaVersion"`
+	OccurredAt    string             `json:"occurredAt"`
+	Attribute     string             `json:"attribute"`
+	SupertypeID   string             `json:"supertypeID"`
+	Producer      string             `json:"producer"`
+	Sequence      int64              `json:"sequence"`
+	Payload       ObservationPayload `json:"payload"`
+}
+
+// ObservationPayload is the encrypted observation carried by an envelope, along with the producer's signature if it signed it
+type ObservationPayload struct {
+	Ciphertext string `json:"ciphertext"`
+	IV         string `json:"iv"`
+	PublicKey  string `json:"pk"`
+	Signature  string `json:"signature,omitempty"`
+	Timestamp  int64  `json:"timestamp,omitempty"`
+}
+
+// Body renders the event in the given schema version
+func (e ObservationEvent) Body(version int) ([]byte, error) {
+	switch version {
+	case dashboard.SchemaVersionLegacy:
+		return json.Marshal(e.legacy())
+	case dashboard.SchemaVersionEnvelope:
+		return json.Marshal(e.envelope())
+	}
+	return nil, dashboard.ErrInvalidSchemaVersion
+}
+
+// envelope wraps the event for schema version 2
+func (e ObservationEvent) envelope() Envelope {
+	eventType := e.Type
+	if eventType == "" {
+		eventType = ObservationEventType
+	}
+
+	return Envelope{
+		ID:            e.ID,
+		Type:          eventType,
+		SchemaVersion: dashboard.SchemaVersionEnvelope,
+		OccurredAt:    e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		Attribute:     e.Attribute,
+		SupertypeID:   e.SupertypeID,
+		Producer:      e.Producer,
+		Sequence:      e.Sequence,
+		Payload: ObservationPayload{
+			Ciphertext: e.Ciphertext,
+			IV:         e.IV,
+			PublicKey:  e.PublicKey,
+			Signature:  e.Signature,
+			Timestamp:  e.Timestamp,
+		},
+	}
+}
+
+// legacy flattens the event into the schema version 1 body, where every field is a string
+// and the ciphertext carries the IV and attribute as it's stored
+func (e ObservationEvent) legacy() map[string]string {
+	body := map[string]string{
+		"dateAdded":   e.OccurredAt.Format(legacyDateFormat),
+		"ciphertext":  e.Ciphertext + "|" + e.IV + "|" + e.Attribute,
+		"pk":          e.PublicKey,
+		"supertypeID": e.SupertypeID,
+		"attribute":   e.Attribute,
+		"sequence":    strconv.FormatInt(e.Sequence, 10),
+	}
+	if e.Type != "" {
+		body["type"] = e.Type
+	}
+	// Pass the producer's signature through so consumers can verify the observation themselves
+	if e.Signature != "" {
+		body["signature"] = e.Signature
+		body["timestamp"] = strconv.FormatInt(e.Timestamp, 10)
+	}
+	return body
+}
@@ -0,0 +1,192 @@
+package delivering
+
+import (
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Repository provides access to relevant storage
+type repository interface {
+	GetVendorUsername(string) (*string, error)
+	ListAttempts(string, Filter) ([]Attempt, error)
+	GetAttempts(string) ([]Attempt, error)
+	ListDeadLetters(string, Filter) ([]DeadLetter, error)
+	GetDeadLetter(string) (*DeadLetter, error)
+	PutDeadLetter(DeadLetter) error
+	DeleteDeadLetter(string) error
+}
+
+// Service provides webhook delivery operations for vendors
+type Service interface {
+	ListAttempts(Filter, string) ([]Attempt, error)
+	GetDelivery(string, string) (*DeliveryReport, error)
+	ListFailures(Filter, string) ([]DeadLetter, error)
+	Replay(ReplayRequest, string) (*ReplayResult, error)
+}
+
+type service struct {
+	r repository
+	d *Dispatcher
+}
+
+// NewService creates a delivering service with the necessary dependencies
+func NewService(r repository, d *Dispatcher) Service {
+	return &service{r, d}
+}
+
+// ListAttempts returns every attempt at sending the vendor's webhooks matching the filter, oldest first
+func (s *service) ListAttempts(f Filter, apiKey string) ([]Attempt, error) {
+	vendor, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err = normalizeFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.r.ListAttempts(*vendor, f)
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// GetDelivery returns every attempt at one of the vendor's deliveries, and its dead letter if we gave up on it
+func (s *service) GetDelivery(deliveryID string, apiKey string) (*DeliveryReport, error) {
+	vendor, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.r.GetAttempts(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	deadLetter, err := s.r.GetDeadLetter(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't reveal whether another vendor's delivery exists
+	owned := deadLetter != nil && deadLetter.Vendor == *vendor
+	for _, attempt := range attempts {
+		owned = owned || attempt.Vendor == *vendor
+	}
+	if !owned {
+		return nil, ErrDeliveryNotFound
+	}
+
+	return &DeliveryReport{
+		DeliveryID: deliveryID,
+		Attempts:   attempts,
+		DeadLetter: deadLetter,
+	}, nil
+}
+
+// ListFailures returns the vendor's dead-lettered deliveries matching the filter, oldest first
+func (s *service) ListFailures(f Filter, apiKey string) ([]DeadLetter, error) {
+	vendor, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err = normalizeFilter(f)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := s.r.ListDeadLetters(*vendor, f)
+	if err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+// Replay queues dead-lettered deliveries to be sent again, with a fresh allowance of attempts
+// Replayed deliveries keep their ID, so receivers that already processed them can tell,
+// and their attempts are numbered on from the earlier ones so the delivery's history is kept
+func (s *service) Replay(replayRequest ReplayRequest, apiKey string) (*ReplayResult, error) {
+	vendor, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var deadLetters []DeadLetter
+	if len(replayRequest.IDs) > 0 {
+		for _, id := range replayRequest.IDs {
+			deadLetter, err := s.r.GetDeadLetter(id)
+			if err != nil {
+				return nil, err
+			}
+			if deadLetter == nil || deadLetter.Vendor != *vendor {
+				return nil, ErrDeliveryNotFound
+			}
+			deadLetters = append(deadLetters, *deadLetter)
+		}
+	} else {
+		if replayRequest.Since == "" && replayRequest.Until == "" {
+			return nil, ErrEmptyReplay
+		}
+		f, err := normalizeFilter(Filter{Since: replayRequest.Since, Until: replayRequest.Until})
+		if err != nil {
+			return nil, err
+		}
+		deadLetters, err = s.r.ListDeadLetters(*vendor, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := ReplayResult{Replayed: []string{}}
+	for _, deadLetter := range deadLetters {
+		// Remove the dead letter first, if the replay fails too it will be dead-lettered again under the same ID
+		err = s.r.DeleteDeadLetter(deadLetter.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt, _ := time.Parse(TimeFormat, deadLetter.CreatedAt)
+		err = s.d.Enqueue(Delivery{
+			ID:             deadLetter.ID,
+			SubscriptionID: deadLetter.SubscriptionID,
+			Endpoint:       deadLetter.Endpoint,
+			Vendor:         deadLetter.Vendor,
+			SupertypeID:    deadLetter.SupertypeID,
+			Attribute:      deadLetter.Attribute,
+			Sequence:       deadLetter.Sequence,
+			Events:         deadLetter.Events,
+			Body:           []byte(deadLetter.Body),
+			Attempt:        deadLetter.Attempts,
+			PriorAttempts:  deadLetter.Attempts,
+			CreatedAt:      createdAt,
+		})
+		if err != nil {
+			// Nothing was queued, so put the dead letter back for a later replay
+			if putErr := s.r.PutDeadLetter(deadLetter); putErr != nil {
+				color.Red("Failed to restore dead letter %v: %v", deadLetter.ID, putErr)
+			}
+			return &result, err
+		}
+		result.Replayed = append(result.Replayed, deadLetter.ID)
+	}
+
+	return &result, nil
+}
+
+// normalizeFilter rewrites RFC 3339 filter times in TimeFormat so they compare correctly with stored times
+func normalizeFilter(f Filter) (Filter, error) {
+	for _, t := range []*string{&f.Since, &f.Until} {
+		if *t == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, *t)
+		if err != nil {
+			return f, ErrInvalidTimeFilter
+		}
+		*t = parsed.UTC().Format(TimeFormat)
+	}
+	return f, nil
+}
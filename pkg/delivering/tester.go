@@ -0,0 +1,92 @@
+package delivering
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/super-type/supertype/internal/netguard"
+	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/signing"
+)
+
+// TestEventType is the type of the synthetic events sent when a vendor test-fires a webhook
+const TestEventType = "webhook.test"
+
+// TestSupertypeID stands in for the user in test events
+const TestSupertypeID = "test"
+
+// maxTestResponseExcerpt bounds how much of an endpoint's answer to a test event is returned to the vendor
+const maxTestResponseExcerpt = 1024
+
+// TestFire POSTs a synthetic event for the attribute to the subscription's endpoint, signed and authenticated like
+// any other delivery, and reports the endpoint's status code, latency and the start of its response
+// Failing to reach the endpoint is part of the result, only refused addresses and unusable credentials are errors
+func (v *Verifier) TestFire(subscription dashboard.Subscription, attribute string) (*dashboard.TestDeliveryResult, error) {
+	now := time.Now()
+	result := dashboard.TestDeliveryResult{
+		Endpoint:   subscription.Endpoint,
+		DeliveryID: uuid.New().String(),
+		Attribute:  attribute,
+	}
+
+	// Test events look like real ones in the subscription's schema version, without any data
+	event := ObservationEvent{
+		ID:          uuid.New().String(),
+		Type:        TestEventType,
+		OccurredAt:  now,
+		Attribute:   attribute,
+		SupertypeID: TestSupertypeID,
+	}
+	body, err := event.Body(subscription.EventSchemaVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	err = applyCredentials(req, subscription)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Supertype-Delivery", result.DeliveryID)
+	req.Header.Set(signing.WebhookSignatureHeader, signing.WebhookSignatureHeaderValue(now.Unix(), body, subscription.SigningSecrets(now)...))
+
+	start := time.Now()
+	resp, err := v.client.Do(req)
+	if err != nil {
+		if netguard.Unsafe(err) {
+			return nil, err
+		}
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.Error = err.Error()
+		return &result, nil
+	}
+	defer resp.Body.Close()
+
+	excerpt, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTestResponseExcerpt+1))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.StatusCode = resp.StatusCode
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if len(excerpt) > maxTestResponseExcerpt {
+		excerpt = excerpt[:maxTestResponseExcerpt]
+		result.BodyTruncated = true
+	}
+	result.Body = string(bytes.ToValidUTF8(excerpt, []byte(string(utf8.RuneError))))
+
+	return &result, nil
+}
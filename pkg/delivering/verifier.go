@@ -0,0 +1,109 @@
+package delivering
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/super-type/supertype/internal/netguard"
+	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/signing"
+)
+
+// VerificationEventType is the type of the event sent to prove ownership of an endpoint
+const VerificationEventType = "webhook.verification"
+
+// maxChallengeResponse bounds how much of an endpoint's answer to a challenge we read
+const maxChallengeResponse = 4096
+
+// challengeResponse is the JSON an endpoint may answer a challenge with
+// Echoing the challenge back as JSON isn't accepted, since any endpoint that echoes its request body would pass
+type challengeResponse struct {
+	Signature string `json:"signature"`
+}
+
+// Verifier checks that whoever registered a webhook controls the endpoint, using a challenge-response handshake
+type Verifier struct {
+	client  *http.Client
+	timeout time.Duration
+}
+
+// NewVerifier creates a verifier whose challenges time out after timeout
+func NewVerifier(timeout time.Duration) *Verifier {
+	return &Verifier{
+		client:  netguard.NewClient(timeout),
+		timeout: timeout,
+	}
+}
+
+// Challenge POSTs a random challenge to the subscription's endpoint, signed and authenticated like any other delivery
+// The endpoint passes if it responds 2xx with the challenge verbatim as its whole body,
+// or with {"signature": "<HEX HMAC-SHA256 OF THE CHALLENGE USING THE SUBSCRIPTION SECRET>"}
+func (v *Verifier) Challenge(subscription dashboard.Subscription) error {
+	secret := subscription.Secret
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	challenge := hex.EncodeToString(b)
+
+	body, err := json.Marshal(map[string]string{
+		"type":      VerificationEventType,
+		"challenge": challenge,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	err = applyCredentials(req, subscription)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signing.WebhookSignatureHeader, signing.WebhookSignatureHeaderValue(time.Now().Unix(), body, secret))
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChallengeFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: endpoint responded %d", ErrChallengeFailed, resp.StatusCode)
+	}
+
+	answer, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxChallengeResponse))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChallengeFailed, err)
+	}
+
+	if strings.TrimSpace(string(answer)) == challenge {
+		return nil
+	}
+
+	var parsed challengeResponse
+	if json.Unmarshal(answer, &parsed) == nil && parsed.Signature != "" &&
+		hmac.Equal([]byte(parsed.Signature), []byte(signing.SignChallenge(secret, challenge))) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: endpoint didn't answer the challenge", ErrChallengeFailed)
+}
@@ -0,0 +1,93 @@
+package delivering
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/signing"
+)
+
+// setenv sets an environment variable for the rest of the test, restoring it afterwards
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestChallenge(t *testing.T) {
+	setenv(t, "SUPERTYPE_ENV", "development")
+	setenv(t, "WEBHOOK_ALLOWED_HOSTS", "127.0.0.1")
+
+	const secret = "secret"
+	tests := []struct {
+		name   string
+		answer func(body []byte, challenge string) string
+		pass   bool
+	}{
+		{
+			name:   "bare challenge",
+			answer: func(body []byte, challenge string) string { return challenge + "\n" },
+			pass:   true,
+		},
+		{
+			name: "signed challenge",
+			answer: func(body []byte, challenge string) string {
+				return `{"signature": "` + signing.SignChallenge(secret, challenge) + `"}`
+			},
+			pass: true,
+		},
+		{
+			name:   "echoed request",
+			answer: func(body []byte, challenge string) string { return string(body) },
+		},
+		{
+			name:   "challenge as JSON",
+			answer: func(body []byte, challenge string) string { return `{"challenge": "` + challenge + `"}` },
+		},
+		{
+			name: "signed with another secret",
+			answer: func(body []byte, challenge string) string {
+				return `{"signature": "` + signing.SignChallenge("other", challenge) + `"}`
+			},
+		},
+		{
+			name:   "empty",
+			answer: func(body []byte, challenge string) string { return "" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				var request struct {
+					Challenge string `json:"challenge"`
+				}
+				json.Unmarshal(body, &request)
+				w.Write([]byte(tt.answer(body, request.Challenge)))
+			}))
+			defer endpoint.Close()
+
+			err := NewVerifier(time.Second).Challenge(dashboard.Subscription{Endpoint: endpoint.URL, Secret: secret})
+			if tt.pass && err != nil {
+				t.Fatalf("Challenge() = %v, want it to pass", err)
+			}
+			if !tt.pass && !errors.Is(err, ErrChallengeFailed) {
+				t.Fatalf("Challenge() = %v, want %v", err, ErrChallengeFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/auditing"
+	"github.com/super-type/supertype/pkg/authenticating"
+	httpUtil "github.com/super-type/supertype/pkg/http"
+)
+
+// getAccessLog returns a handler for POST /access-log requests
+// Users log in with their own credentials to see which vendors accessed their data
+func getAccessLog(a authenticating.Service, al accesslog.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var user authenticating.UserPassword
+		err = decoder.Decode(&user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		authenticatedUser, err := a.LoginUser(user)
+		if err != nil {
+			audit(au, r, auditing.EventLoginFailed, user.Username, auditing.OutcomeFailure, err.Error())
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		log, err := al.Get(authenticatedUser.SupertypeID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(log)
+	}
+}
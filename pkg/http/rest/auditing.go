@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/auditing"
+	httpUtil "github.com/super-type/supertype/pkg/http"
+)
+
+// audit records a security-relevant event for the request in the audit log
+func audit(au auditing.Service, r *http.Request, eventType string, actor string, outcome string, details string) {
+	au.Record(auditing.Event{
+		Type:    eventType,
+		Actor:   actor,
+		IP:      httpUtil.ClientIP(r),
+		Outcome: outcome,
+		Details: details,
+	})
+}
+
+// apiKeyActor identifies a vendor in the audit log by the hash of their API key, never the key itself
+func apiKeyActor(apiKey string) string {
+	return "apiKeyHash:" + utils.GetAPIKeyHash(apiKey)
+}
+
+// listAuditLog returns a handler for GET /admin/audit-log requests
+// Events are returned as a JSON array, or as JSON lines for export with ?format=jsonl
+func listAuditLog(au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := auditing.Filter{
+			Type:  query.Get("type"),
+			Actor: query.Get("actor"),
+			Since: query.Get("since"),
+			Until: query.Get("until"),
+		}
+
+		events, err := au.List(filter)
+		if err == auditing.ErrInvalidTimeFilter {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if query.Get("format") == "jsonl" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit-log.jsonl"`)
+			encoder := json.NewEncoder(w)
+			for _, event := range events {
+				encoder.Encode(event)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	}
+}
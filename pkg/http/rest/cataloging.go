@@ -0,0 +1,217 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/auditing"
+	"github.com/super-type/supertype/pkg/cataloging"
+	httpUtil "github.com/super-type/supertype/pkg/http"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// catalogError writes the response for a failed catalog request
+func catalogError(w http.ResponseWriter, err error) {
+	if errors.Is(err, cataloging.ErrInvalidDefinition) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch err {
+	case cataloging.ErrMissingAuthor, cataloging.ErrInvalidReplacement, cataloging.ErrInvalidListFilter:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case cataloging.ErrUnknownAttribute:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case cataloging.ErrNotNamespaceOwner:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case cataloging.ErrAttributeExists, cataloging.ErrVersionConflict, cataloging.ErrAlreadyDeprecated:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// listAttributes returns a handler for GET /list-attributes requests
+// Attributes are listed flat unless ?format=tree, with the figures of the vendor whose token authorized the request
+func listAttributes(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := cataloging.ListFilter{
+			Prefix:     query.Get("prefix"),
+			Room:       query.Get("room"),
+			Device:     query.Get("device"),
+			Deprecated: query.Get("deprecated"),
+		}
+		format := query.Get("format")
+		if format != "" && format != cataloging.FormatFlat && format != cataloging.FormatTree {
+			catalogError(w, cataloging.ErrInvalidListFilter)
+			return
+		}
+
+		listed, err := ca.ListAttributes(filter, utils.AuthorizedUsername(r))
+		if err != nil {
+			catalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if format == cataloging.FormatTree {
+			json.NewEncoder(w).Encode(cataloging.BuildListingTree(listed))
+			return
+		}
+		json.NewEncoder(w).Encode(listed)
+	}
+}
+
+// getAttributeCatalog returns a handler for GET /attribute-catalog requests
+func getAttributeCatalog(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ca.Tree())
+	}
+}
+
+// getCatalogChangelog returns a handler for GET /attribute-catalog/changelog requests, optionally for one ?path=
+func getCatalogChangelog(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changes, err := ca.Changelog(r.URL.Query().Get("path"))
+		if err != nil {
+			catalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(changes)
+	}
+}
+
+// createAttribute returns a handler for POST /admin/create-attribute requests
+func createAttribute(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var attributeRequest cataloging.AttributeRequest
+		err = decoder.Decode(&attributeRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		attributeRequest.Author = utils.AdminName(r)
+
+		attribute, err := ca.CreateAttribute(attributeRequest)
+		if err != nil {
+			catalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(attribute)
+	}
+}
+
+// updateAttribute returns a handler for POST /admin/update-attribute requests
+func updateAttribute(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var attributeRequest cataloging.AttributeRequest
+		err = decoder.Decode(&attributeRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		attributeRequest.Author = utils.AdminName(r)
+
+		attribute, err := ca.UpdateAttribute(attributeRequest)
+		if err != nil {
+			catalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attribute)
+	}
+}
+
+// deprecateAttribute returns a handler for POST /admin/deprecate-attribute requests
+func deprecateAttribute(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var deprecateRequest cataloging.DeprecateRequest
+		err = decoder.Decode(&deprecateRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		deprecateRequest.Author = utils.AdminName(r)
+
+		attribute, err := ca.DeprecateAttribute(deprecateRequest)
+		if err != nil {
+			catalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attribute)
+	}
+}
+
+// defineVendorAttribute returns a handler for POST /define-attribute requests
+func defineVendorAttribute(ca cataloging.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var attributeRequest cataloging.VendorAttributeRequest
+		err = decoder.Decode(&attributeRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		attribute, err := ca.DefineVendorAttribute(attributeRequest, apiKey)
+		if err == storage.ErrAPIKeyDoesNotMatch {
+			audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			catalogError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if attribute.Version == 1 {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(attribute)
+	}
+}
+
+// listAttributeProposals returns a handler for GET /admin/attribute-proposals requests
+func listAttributeProposals(ca cataloging.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ca.Proposals())
+	}
+}
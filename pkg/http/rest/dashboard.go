@@ -0,0 +1,281 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/super-type/supertype/internal/netguard"
+	"github.com/super-type/supertype/pkg/auditing"
+	"github.com/super-type/supertype/pkg/cataloging"
+	"github.com/super-type/supertype/pkg/dashboard"
+	httpUtil "github.com/super-type/supertype/pkg/http"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// webhookError writes a dashboard webhook error with a matching status code, auditing API key mismatches
+func webhookError(w http.ResponseWriter, r *http.Request, au auditing.Service, apiKey string, err error) {
+	if netguard.Unsafe(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch err {
+	case storage.ErrAPIKeyDoesNotMatch:
+		audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case dashboard.ErrSubscriptionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case dashboard.ErrSubscriptionNotOwned, dashboard.ErrUserNotConnected:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case dashboard.ErrInvalidAttributePattern, dashboard.ErrInvalidDeliveryMode, dashboard.ErrInvalidCredentials, dashboard.ErrAttributeNotSubscribed,
+		dashboard.ErrInvalidScope, dashboard.ErrInvalidUserTags, dashboard.ErrInvalidSchemaVersion,
+		cataloging.ErrNoMatchingAttributes:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case dashboard.ErrSubscriptionPaused, dashboard.ErrSubscriptionNotPaused, dashboard.ErrWebhookAlreadyRegistered, dashboard.ErrSubscriptionNotVerified:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// listWebhooks returns a handler for GET /list-webhooks requests
+func listWebhooks(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		webhooks, err := d.ListWebhooks(apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhooks)
+	}
+}
+
+// pauseWebhook returns a handler for POST /pause-webhook requests
+func pauseWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var webhookRequest dashboard.WebhookRequest
+		err = decoder.Decode(&webhookRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		webhook, err := d.PauseWebhook(webhookRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhook)
+	}
+}
+
+// resumeWebhook returns a handler for POST /resume-webhook requests
+func resumeWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var webhookRequest dashboard.WebhookRequest
+		err = decoder.Decode(&webhookRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		result, err := d.ResumeWebhook(webhookRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// updateWebhook returns a handler for POST /update-webhook requests
+func updateWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var updateRequest dashboard.UpdateWebhookRequest
+		err = decoder.Decode(&updateRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if updateRequest.NewEndpoint == "" && updateRequest.Attribute == "" && updateRequest.Delivery == nil && updateRequest.Scope == nil && updateRequest.SchemaVersion == 0 {
+			http.Error(w, "newEndpoint, attribute, delivery, scope or schemaVersion is required", http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		result, err := d.UpdateWebhook(updateRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// unregisterWebhook returns a handler for POST /unregister-webhook requests
+func unregisterWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var webhookRequest dashboard.WebhookRequest
+		err = decoder.Decode(&webhookRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		err = d.UnregisterWebhook(webhookRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// setWebhookCredentials returns a handler for POST /set-webhook-credentials requests
+func setWebhookCredentials(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var credentialsRequest dashboard.CredentialsRequest
+		err = decoder.Decode(&credentialsRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		webhook, err := d.SetWebhookCredentials(credentialsRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhook)
+	}
+}
+
+// testWebhook returns a handler for POST /test-webhook requests
+func testWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var testRequest dashboard.TestWebhookRequest
+		err = decoder.Decode(&testRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		result, err := d.TestWebhook(testRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// tagUser returns a handler for POST /tag-user requests
+func tagUser(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var tagsRequest dashboard.UserTagsRequest
+		err = decoder.Decode(&tagsRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if tagsRequest.SupertypeID == "" {
+			http.Error(w, "supertypeID is required", http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		err = d.TagUser(tagsRequest, apiKey)
+		if err != nil {
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/super-type/supertype/pkg/auditing"
+	"github.com/super-type/supertype/pkg/delivering"
+	httpUtil "github.com/super-type/supertype/pkg/http"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// deliveryError writes a delivering error with a matching status code, auditing API key mismatches
+func deliveryError(w http.ResponseWriter, r *http.Request, au auditing.Service, apiKey string, err error) {
+	switch err {
+	case storage.ErrAPIKeyDoesNotMatch:
+		audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case delivering.ErrDeliveryNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case delivering.ErrInvalidTimeFilter, delivering.ErrEmptyReplay:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// deliveryFilter reads a delivering filter from the query string
+func deliveryFilter(r *http.Request) delivering.Filter {
+	query := r.URL.Query()
+	return delivering.Filter{
+		Endpoint: query.Get("endpoint"),
+		Since:    query.Get("since"),
+		Until:    query.Get("until"),
+	}
+}
+
+// listWebhookDeliveries returns a handler for GET /webhook-deliveries requests
+func listWebhookDeliveries(dl delivering.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		attempts, err := dl.ListAttempts(deliveryFilter(r), apiKey)
+		if err != nil {
+			deliveryError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attempts)
+	}
+}
+
+// getWebhookDelivery returns a handler for GET /webhook-deliveries/{id} requests
+func getWebhookDelivery(dl delivering.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		report, err := dl.GetDelivery(mux.Vars(r)["id"], apiKey)
+		if err != nil {
+			deliveryError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// listWebhookFailures returns a handler for GET /webhook-failures requests
+func listWebhookFailures(dl delivering.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		deadLetters, err := dl.ListFailures(deliveryFilter(r), apiKey)
+		if err != nil {
+			deliveryError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deadLetters)
+	}
+}
+
+// replayWebhooks returns a handler for POST /replay-webhooks requests
+func replayWebhooks(dl delivering.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var replayRequest delivering.ReplayRequest
+		err = decoder.Decode(&replayRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		result, err := dl.Replay(replayRequest, apiKey)
+		if err != nil {
+			deliveryError(w, r, au, apiKey, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -6,28 +6,59 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/auditing"
 	"github.com/super-type/supertype/pkg/authenticating"
+	"github.com/super-type/supertype/pkg/cataloging"
 	"github.com/super-type/supertype/pkg/consuming"
 	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/delivering"
 	httpUtil "github.com/super-type/supertype/pkg/http"
+	"github.com/super-type/supertype/pkg/idempotency"
+	"github.com/super-type/supertype/pkg/notifying"
 	"github.com/super-type/supertype/pkg/producing"
+	"github.com/super-type/supertype/pkg/storage"
 )
 
 // Router is the main router for the application
-func Router(a authenticating.Service, p producing.Service, c consuming.Service, d dashboard.Service) *mux.Router {
+func Router(a authenticating.Service, p producing.Service, c consuming.Service, d dashboard.Service, i idempotency.Service, au auditing.Service, al accesslog.Service, dl delivering.Service, n notifying.Service, ca cataloging.Service) *mux.Router {
 	router := mux.NewRouter()
 
 	// TODO change camel-cased URLs
 	router.HandleFunc("/healthcheck", healthcheck()).Methods("GET", "OPTIONS")
-	router.HandleFunc("/loginVendor", loginVendor(a)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/authorized-login-user", authorizedLoginUser(a)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/createVendor", createVendor(a)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/loginUser", loginUser(a)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/createUser", createUser(a)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/consume", consume(c)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/produce", produce(p)).Methods("POST", "OPTIONS")
-	router.HandleFunc("/list-attributes", utils.IsAuthorized(listAttributes(d))).Methods("GET", "OPTIONS")
-	router.HandleFunc("/register-webhook", registerWebhook(d)).Methods("POST", "OPTIONS") // TODO do we need isAuthorized()?
+	router.HandleFunc("/loginVendor", loginVendor(a, au)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/authorized-login-user", utils.IsSigned(a, au, authorizedLoginUser(a, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/createVendor", utils.IdempotentOutcome(i, createVendor(a))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/loginUser", loginUser(a, au)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/createUser", utils.Idempotent(i, createUser(a))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/consume", utils.IsSigned(a, au, consume(c, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/produce", utils.IsSigned(a, au, utils.Idempotent(i, produce(p, au)))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/list-attributes", utils.IsAuthorized(listAttributes(ca))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/attribute-catalog", getAttributeCatalog(ca)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/attribute-catalog/changelog", getCatalogChangelog(ca)).Methods("GET", "OPTIONS")
+	router.HandleFunc("/define-attribute", utils.IsSigned(a, au, defineVendorAttribute(ca, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/register-webhook", utils.IsSigned(a, au, registerWebhook(d, au))).Methods("POST", "OPTIONS") // TODO do we need isAuthorized()?
+	router.HandleFunc("/rotate-webhook-secret", utils.IsSigned(a, au, rotateWebhookSecret(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/verify-webhook", utils.IsSigned(a, au, verifyWebhook(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/list-webhooks", utils.IsSigned(a, au, listWebhooks(d, au))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/pause-webhook", utils.IsSigned(a, au, pauseWebhook(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/resume-webhook", utils.IsSigned(a, au, resumeWebhook(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/update-webhook", utils.IsSigned(a, au, updateWebhook(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/unregister-webhook", utils.IsSigned(a, au, unregisterWebhook(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/set-webhook-credentials", utils.IsSigned(a, au, setWebhookCredentials(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/test-webhook", utils.IsSigned(a, au, testWebhook(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/tag-user", utils.IsSigned(a, au, tagUser(d, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/webhook-deliveries", utils.IsSigned(a, au, listWebhookDeliveries(dl, au))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/webhook-deliveries/{id}", utils.IsSigned(a, au, getWebhookDelivery(dl, au))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/webhook-failures", utils.IsSigned(a, au, listWebhookFailures(dl, au))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/replay-webhooks", utils.IsSigned(a, au, replayWebhooks(dl, au))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/notifications", utils.IsSigned(a, au, listNotifications(n, au))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/access-log", getAccessLog(a, al, au)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/audit-log", utils.IsAdmin(listAuditLog(au))).Methods("GET", "OPTIONS")
+	router.HandleFunc("/admin/create-attribute", utils.IsAdmin(createAttribute(ca))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/update-attribute", utils.IsAdmin(updateAttribute(ca))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/deprecate-attribute", utils.IsAdmin(deprecateAttribute(ca))).Methods("POST", "OPTIONS")
+	router.HandleFunc("/admin/attribute-proposals", utils.IsAdmin(listAttributeProposals(ca))).Methods("GET", "OPTIONS")
 	return router
 }
 
@@ -38,7 +69,7 @@ func healthcheck() func(w http.ResponseWriter, r *http.Request) {
 }
 
 // loginVendor returns a handler for POST /loginVendor requests
-func loginVendor(a authenticating.Service) func(w http.ResponseWriter, r *http.Request) {
+func loginVendor(a authenticating.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
@@ -59,6 +90,7 @@ func loginVendor(a authenticating.Service) func(w http.ResponseWriter, r *http.R
 
 		result, err := a.LoginVendor(vendor)
 		if err != nil {
+			audit(au, r, auditing.EventLoginFailed, vendor.Username, auditing.OutcomeFailure, err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -117,7 +149,7 @@ func createVendor(a authenticating.Service) func(w http.ResponseWriter, r *http.
 	}
 }
 
-func loginUser(a authenticating.Service) func(w http.ResponseWriter, r *http.Request) {
+func loginUser(a authenticating.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
@@ -137,6 +169,7 @@ func loginUser(a authenticating.Service) func(w http.ResponseWriter, r *http.Req
 
 		result, err := a.LoginUser(user)
 		if err != nil {
+			audit(au, r, auditing.EventLoginFailed, user.Username, auditing.OutcomeFailure, err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -146,7 +179,7 @@ func loginUser(a authenticating.Service) func(w http.ResponseWriter, r *http.Req
 	}
 }
 
-func authorizedLoginUser(a authenticating.Service) func(w http.ResponseWriter, r *http.Request) {
+func authorizedLoginUser(a authenticating.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
@@ -171,10 +204,18 @@ func authorizedLoginUser(a authenticating.Service) func(w http.ResponseWriter, r
 
 		result, err := a.AuthorizedLoginUser(user, apiKey)
 		if err != nil {
+			if err == storage.ErrAPIKeyDoesNotMatch {
+				audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			} else {
+				audit(au, r, auditing.EventLoginFailed, user.Username, auditing.OutcomeFailure, err.Error())
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// Logging in through a vendor links that vendor to the user
+		audit(au, r, auditing.EventConsentGranted, user.Username, auditing.OutcomeSuccess, apiKeyActor(apiKey))
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(result)
 	}
@@ -209,7 +250,7 @@ func createUser(a authenticating.Service) func(w http.ResponseWriter, r *http.Re
 	}
 }
 
-func produce(p producing.Service) func(w http.ResponseWriter, r *http.Request) {
+func produce(p producing.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
@@ -233,7 +274,21 @@ func produce(p producing.Service) func(w http.ResponseWriter, r *http.Request) {
 		}
 
 		err = p.Produce(observation, apiKey)
-		if err != nil {
+		switch err {
+		case nil:
+		case producing.ErrReplayedObservation:
+			audit(au, r, auditing.EventObservationReplayed, apiKeyActor(apiKey), auditing.OutcomeDenied, observation.Nonce)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case storage.ErrAPIKeyDoesNotMatch:
+			audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case producing.ErrMissingObservationTimestamp, producing.ErrMissingObservationNonce, producing.ErrStaleObservation, producing.ErrInvalidAttribute,
+			cataloging.ErrUnknownAttribute:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -242,7 +297,7 @@ func produce(p producing.Service) func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func consume(c consuming.Service) func(w http.ResponseWriter, r *http.Request) {
+func consume(c consuming.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
@@ -266,6 +321,15 @@ func consume(c consuming.Service) func(w http.ResponseWriter, r *http.Request) {
 		}
 
 		res, err := c.Consume(observation, apiKey)
+		if err == storage.ErrAPIKeyDoesNotMatch {
+			audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err == cataloging.ErrUnknownAttribute {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -276,35 +340,101 @@ func consume(c consuming.Service) func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func listAttributes(d dashboard.Service) func(w http.ResponseWriter, r *http.Request) {
+func registerWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		observations, err := d.ListAttributes()
+		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		var webhookRequest dashboard.WebhookRequest
+		err = decoder.Decode(&webhookRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if &webhookRequest == nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		secret, err := d.RegisterWebhook(webhookRequest, apiKey)
+		if err == storage.ErrAPIKeyDoesNotMatch {
+			audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			audit(au, r, auditing.EventWebhookRegistered, apiKeyActor(apiKey), auditing.OutcomeFailure, webhookRequest.Endpoint+": "+err.Error())
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+		audit(au, r, auditing.EventWebhookRegistered, apiKeyActor(apiKey), auditing.OutcomeSuccess, webhookRequest.Endpoint)
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(observations)
+		json.NewEncoder(w).Encode(secret)
 	}
 }
 
-func registerWebhook(d dashboard.Service) func(w http.ResponseWriter, r *http.Request) {
+func rotateWebhookSecret(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		decoder, err := httpUtil.LocalHeaders(w, r)
 		if err != nil {
 			return
 		}
 
-		var webhookRequest dashboard.WebhookRequest
-		err = decoder.Decode(&webhookRequest)
+		var rotateRequest dashboard.RotateSecretRequest
+		err = decoder.Decode(&rotateRequest)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if &webhookRequest == nil {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		secret, err := d.RotateWebhookSecret(rotateRequest, apiKey)
+		switch err {
+		case nil:
+		case storage.ErrAPIKeyDoesNotMatch:
+			audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case dashboard.ErrSubscriptionNotOwned:
+			audit(au, r, auditing.EventKeyRotated, apiKeyActor(apiKey), auditing.OutcomeDenied, rotateRequest.Endpoint)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		default:
+			webhookError(w, r, au, apiKey, err)
+			return
+		}
+		audit(au, r, auditing.EventKeyRotated, apiKeyActor(apiKey), auditing.OutcomeSuccess, rotateRequest.Endpoint)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(secret)
+	}
+}
+
+// verifyWebhook returns a handler for POST /verify-webhook requests
+func verifyWebhook(d dashboard.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decoder, err := httpUtil.LocalHeaders(w, r)
+		if err != nil {
+			return
+		}
+
+		var verifyRequest dashboard.VerifyRequest
+		err = decoder.Decode(&verifyRequest)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
 		apiKey := r.Header.Get("X-API-Key")
@@ -312,13 +442,13 @@ func registerWebhook(d dashboard.Service) func(w http.ResponseWriter, r *http.Re
 			return
 		}
 
-		err = d.RegisterWebhook(webhookRequest, apiKey)
+		result, err := d.VerifyWebhook(verifyRequest, apiKey)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			webhookError(w, r, au, apiKey, err)
 			return
 		}
 
-		// w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode("OK") // todo do something better here
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
 	}
 }
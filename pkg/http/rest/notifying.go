@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/super-type/supertype/pkg/auditing"
+	"github.com/super-type/supertype/pkg/notifying"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// listNotifications returns a handler for GET /notifications requests
+func listNotifications(n notifying.Service, au auditing.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			return
+		}
+
+		notifications, err := n.List(apiKey)
+		switch err {
+		case nil:
+		case storage.ErrAPIKeyDoesNotMatch:
+			audit(au, r, auditing.EventAPIKeyMismatch, apiKeyActor(apiKey), auditing.OutcomeDenied, r.URL.Path)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notifications)
+	}
+}
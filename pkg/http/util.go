@@ -3,7 +3,9 @@ package http
 import (
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"strings"
 )
 
 // LocalHeaders sets local headers for local running
@@ -11,7 +13,7 @@ import (
 func LocalHeaders(w http.ResponseWriter, r *http.Request) (*json.Decoder, error) {
 	(w).Header().Set("Access-Control-Allow-Origin", "*")
 	(w).Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	(w).Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, Token")
+	(w).Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, Token, X-Supertype-Timestamp, X-Supertype-Request-Signature, Idempotency-Key")
 	// todo we may still want to leave this but unsure
 	if (r).Method == "OPTIONS" {
 		return nil, errors.New("OPTIONS")
@@ -20,3 +22,28 @@ func LocalHeaders(w http.ResponseWriter, r *http.Request) (*json.Decoder, error)
 	decoder := json.NewDecoder(r.Body)
 	return decoder, nil
 }
+
+// trustedProxyHops is how many of our own proxies append to X-Forwarded-For, the load balancer then nginx (see .ebextensions)
+const trustedProxyHops = 2
+
+// ClientIP returns the originating IP of a request
+// Clients can send their own X-Forwarded-For, so only the entries our proxies appended are trusted, and only when the request
+// came through nginx. The load balancer appends the address it saw, which nginx then follows with the load balancer's own
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() || len(forwarded) == 0 {
+		return host
+	}
+
+	entries := strings.Split(strings.Join(forwarded, ","), ",")
+	i := len(entries) - trustedProxyHops
+	if i < 0 {
+		i = 0
+	}
+	return strings.TrimSpace(entries[i])
+}
@@ -0,0 +1,12 @@
+package idempotency
+
+import "errors"
+
+// ErrRequestInProgress is used when a request with the same idempotency key is still being handled
+var ErrRequestInProgress = errors.New("A request with this Idempotency-Key is already in progress")
+
+// ErrKeyReused is used when an idempotency key is sent again with a different request body
+var ErrKeyReused = errors.New("Idempotency-Key was already used with a different request")
+
+// ErrAlreadyCompleted is used when repeating a request whose response holds secrets, so was never stored for replay
+var ErrAlreadyCompleted = errors.New("A request with this Idempotency-Key already succeeded, and its response can't be replayed")
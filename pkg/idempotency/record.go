@@ -0,0 +1,18 @@
+package idempotency
+
+// StatusInProgress marks a key whose first request is still being handled
+const StatusInProgress = "in-progress"
+
+// StatusComplete marks a key whose response has been stored for replay
+const StatusComplete = "complete"
+
+// Record is a stored idempotency key along with the response to replay for it
+type Record struct {
+	Key         string `json:"idempotencyKey"`
+	RequestHash string `json:"requestHash"`
+	Status      string `json:"status"`
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType"`
+	Body        string `json:"body"`
+	ExpiresAt   int64  `json:"expiresAt"`
+}
@@ -0,0 +1,86 @@
+package idempotency
+
+import "time"
+
+// RetentionWindow is how long a completed response is replayed for repeats of its key
+const RetentionWindow = 24 * time.Hour
+
+// lockTimeout is how long an in-progress key blocks repeats before it's assumed abandoned
+const lockTimeout = time.Minute
+
+// Repository provides access to relevant storage
+type repository interface {
+	ReserveIdempotencyKey(Record) (*Record, error)
+	PutIdempotencyRecord(Record) error
+	DeleteIdempotencyKey(string) error
+}
+
+// Service provides idempotency operations
+type Service interface {
+	Begin(string, string) (*Record, error)
+	Complete(string, string, int, string, []byte) error
+	Release(string) error
+}
+
+type service struct {
+	r repository
+}
+
+// NewService creates an idempotency service with the necessary dependencies
+func NewService(r repository) Service {
+	return &service{r}
+}
+
+// Begin reserves a key for a request, returning the stored record instead if the key was already completed
+func (s *service) Begin(key string, requestHash string) (*Record, error) {
+	existing, err := s.r.ReserveIdempotencyKey(Record{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      StatusInProgress,
+		ExpiresAt:   time.Now().Add(lockTimeout).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// We hold the reservation, so the caller should handle the request
+	if existing == nil {
+		return nil, nil
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, ErrKeyReused
+	}
+
+	if existing.Status != StatusComplete {
+		return nil, ErrRequestInProgress
+	}
+
+	return existing, nil
+}
+
+// Complete stores a response for a reserved key so repeats within the retention window replay it
+func (s *service) Complete(key string, requestHash string, statusCode int, contentType string, body []byte) error {
+	err := s.r.PutIdempotencyRecord(Record{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      StatusComplete,
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        string(body),
+		ExpiresAt:   time.Now().Add(RetentionWindow).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Release drops a reserved key without storing a response, so the request can be retried
+func (s *service) Release(key string) error {
+	err := s.r.DeleteIdempotencyKey(key)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package notifying
+
+// Notification types
+const (
+	TypeWebhookDisabled = "webhook.disabled"
+)
+
+// Notification is a message for a vendor about something that happened to their account
+type Notification struct {
+	ID       string `json:"id"`
+	Vendor   string `json:"vendor"`
+	Type     string `json:"type"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Message  string `json:"message"`
+	Time     string `json:"time"`
+}
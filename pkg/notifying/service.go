@@ -0,0 +1,31 @@
+package notifying
+
+// Repository provides access to relevant storage
+type repository interface {
+	GetVendorUsername(string) (*string, error)
+	ListNotifications(string) ([]Notification, error)
+}
+
+// Service provides notification operations
+type Service interface {
+	List(string) ([]Notification, error)
+}
+
+type service struct {
+	r repository
+}
+
+// NewService creates a notification service with the necessary dependencies
+func NewService(r repository) Service {
+	return &service{r}
+}
+
+// List returns every notification for the vendor with the given API key, newest first
+func (s *service) List(apiKey string) ([]Notification, error) {
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.r.ListNotifications(*username)
+}
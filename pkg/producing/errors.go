@@ -0,0 +1,21 @@
+package producing
+
+import "errors"
+
+// ErrMissingObservationTimestamp is used when an observation doesn't say when it was created
+var ErrMissingObservationTimestamp = errors.New("Observations must include a timestamp")
+
+// ErrInvalidObservationSignature is used when an observation's signature doesn't match the producing vendor's key
+var ErrInvalidObservationSignature = errors.New("Observation signature does not match producing vendor")
+
+// ErrMissingObservationNonce is used when an observation doesn't carry a nonce
+var ErrMissingObservationNonce = errors.New("Observations must include a nonce")
+
+// ErrStaleObservation is used when an observation's timestamp is outside the replay window
+var ErrStaleObservation = errors.New("Observation timestamp is outside the accepted window")
+
+// ErrReplayedObservation is used when an observation's nonce has already been used within the replay window
+var ErrReplayedObservation = errors.New("Observation nonce has already been used - potential replay")
+
+// ErrInvalidAttribute is used when an observation's attribute is empty or contains subscription wildcards
+var ErrInvalidAttribute = errors.New("Invalid observation attribute")
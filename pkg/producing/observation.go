@@ -7,4 +7,7 @@ type ObservationRequest struct {
 	PublicKey   string `json:"pk"`
 	SupertypeID string `json:"supertypeID"`
 	IV          string `json:"iv"`
+	Timestamp   int64  `json:"timestamp"`
+	Nonce       string `json:"nonce"`
+	Signature   string `json:"signature"`
 }
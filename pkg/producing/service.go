@@ -1,8 +1,26 @@
 package producing
 
+import (
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/keys"
+	"github.com/super-type/supertype/pkg/delivering"
+	"github.com/super-type/supertype/pkg/signing"
+)
+
+// ReplayWindow is how far an observation's timestamp may drift from the server clock, and how long its nonce is remembered
+const ReplayWindow = 5 * time.Minute
+
+// maxNonceLength bounds the size of client-supplied nonces we store
+const maxNonceLength = 128
+
 // Repository provides access to relevant storage
 type repository interface {
-	Produce(ObservationRequest, string) error
+	Produce(ObservationRequest, string) ([]delivering.Delivery, []delivering.Delivery, error)
+	GetVendorPublicKey(string) (*string, error)
+	GetVendorUsername(string) (*string, error)
 }
 
 // Service provides producing operations
@@ -10,20 +28,122 @@ type Service interface {
 	Produce(ObservationRequest, string) error
 }
 
+// Dispatcher sends webhook deliveries in the background
+type dispatcher interface {
+	Enqueue(delivering.Delivery) error
+	DeadLetter(delivering.Delivery, error)
+}
+
+// Catalog knows which attributes exist and which vendors can see them
+type catalog interface {
+	ValidateAttribute(string, string) error
+	Visible(string, string) bool
+}
+
 type service struct {
 	r repository
+	d dispatcher
+	c catalog
 }
 
 // NewService creates a producing service with the necessary dependencies
-func NewService(r repository) Service {
-	return &service{r}
+func NewService(r repository, d dispatcher, c catalog) Service {
+	return &service{r, d, c}
 }
 
 // Produce produces encrypted data to Supertype
 func (s *service) Produce(o ObservationRequest, apiKey string) error {
-	err := s.r.Produce(o, apiKey)
+	// Wildcards only belong in subscription patterns, an observation is always for one concrete attribute
+	if o.Attribute == "" || strings.ContainsAny(o.Attribute, "+#") {
+		return ErrInvalidAttribute
+	}
+
+	// Resolving the vendor first also turns away unknown API keys before anything is stored for them
+	username, err := s.r.GetVendorUsername(apiKey)
+	if err != nil {
+		return err
+	}
+	err = s.c.ValidateAttribute(o.Attribute, *username)
+	if err != nil {
+		return err
+	}
+
+	err = checkFreshness(o, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Signatures are optional, but when present they must come from the producing vendor
+	if o.Signature != "" {
+		err = s.verifySignature(o, apiKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The nonce is stored along with the observation, so a request that fails can be retried as it was
+	deliveries, unscoped, err := s.r.Produce(o, apiKey)
+	if err != nil {
+		return err
+	}
+
+	// The observation is already stored, so a delivery that can't be queued is dead-lettered rather than failing the produce
+	// A vendor attribute only goes to subscribers who can see it, however broad their pattern
+	for _, delivery := range deliveries {
+		if !s.c.Visible(delivery.Attribute, delivery.Vendor) {
+			continue
+		}
+		err = s.d.Enqueue(delivery)
+		if err != nil {
+			color.Red("Failed to queue webhook delivery to %v: %v", delivery.Endpoint, err)
+		}
+	}
+	for _, delivery := range unscoped {
+		if s.c.Visible(delivery.Attribute, delivery.Vendor) {
+			s.d.DeadLetter(delivery, delivering.ErrScopeUnavailable)
+		}
+	}
+
+	return nil
+}
+
+// checkFreshness rejects observations without a nonce or whose timestamp is outside the replay window
+func checkFreshness(o ObservationRequest, now time.Time) error {
+	if o.Timestamp == 0 {
+		return ErrMissingObservationTimestamp
+	}
+
+	if o.Nonce == "" || len(o.Nonce) > maxNonceLength {
+		return ErrMissingObservationNonce
+	}
+
+	delta := now.Sub(time.Unix(o.Timestamp, 0))
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta > ReplayWindow {
+		return ErrStaleObservation
+	}
+
+	return nil
+}
+
+// verifySignature checks an observation's signature against the public key of the vendor producing it
+func (s *service) verifySignature(o ObservationRequest, apiKey string) error {
+	pkEncoded, err := s.r.GetVendorPublicKey(apiKey)
 	if err != nil {
 		return err
 	}
+
+	pk, err := keys.ParsePublicKey(*pkEncoded)
+	if err != nil {
+		return err
+	}
+
+	err = signing.VerifyObservation(pk, o.Ciphertext, o.IV, o.Attribute, o.SupertypeID, o.Timestamp, o.Signature)
+	if err != nil {
+		return ErrInvalidObservationSignature
+	}
+
 	return nil
 }
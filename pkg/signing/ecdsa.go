@@ -0,0 +1,52 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+)
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// signECDSA returns the base64-encoded ASN.1 signature of the SHA-256 digest of payload
+func signECDSA(sk *ecdsa.PrivateKey, payload []byte) (string, error) {
+	digest := sha256.Sum256(payload)
+	r, s, err := ecdsa.Sign(rand.Reader, sk, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	der, err := asn1.Marshal(ecdsaSignature{r, s})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// verifyECDSA checks a base64-encoded ASN.1 signature of payload against pk
+func verifyECDSA(pk *ecdsa.PublicKey, payload []byte, signature string) error {
+	der, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	var sig ecdsaSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+		return ErrInvalidSignature
+	}
+
+	digest := sha256.Sum256(payload)
+	if !ecdsa.Verify(pk, digest[:], sig.R, sig.S) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package signing
+
+import "errors"
+
+// ErrInvalidSignatureHeader is used when a signature header can't be parsed
+var ErrInvalidSignatureHeader = errors.New("Invalid signature header")
+
+// ErrTimestampOutOfRange is used when a signed timestamp is outside the accepted window
+var ErrTimestampOutOfRange = errors.New("Signature timestamp outside of accepted window")
+
+// ErrSignatureMismatch is used when none of the provided signatures match the expected one
+var ErrSignatureMismatch = errors.New("Signature does not match")
+
+// ErrInvalidSignature is used when a signature can't be decoded
+var ErrInvalidSignature = errors.New("Invalid signature")
+
+// ErrInvalidTimestamp is used when a signed timestamp can't be parsed
+var ErrInvalidTimestamp = errors.New("Invalid signature timestamp")
@@ -0,0 +1,30 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"strconv"
+	"strings"
+)
+
+// ObservationSigningPayload returns the canonical bytes a producer signs for an observation:
+// the ciphertext, IV, attribute, supertypeID and unix timestamp, separated by newlines
+func ObservationSigningPayload(ciphertext string, iv string, attribute string, supertypeID string, timestamp int64) []byte {
+	return []byte(strings.Join([]string{
+		ciphertext,
+		iv,
+		attribute,
+		supertypeID,
+		strconv.FormatInt(timestamp, 10),
+	}, "\n"))
+}
+
+// SignObservation signs an observation with the producing vendor's secret key
+func SignObservation(sk *ecdsa.PrivateKey, ciphertext string, iv string, attribute string, supertypeID string, timestamp int64) (string, error) {
+	return signECDSA(sk, ObservationSigningPayload(ciphertext, iv, attribute, supertypeID, timestamp))
+}
+
+// VerifyObservation checks an observation's signature against the producing vendor's public key
+// Consumers can call this with the pk delivered alongside the observation to confirm who created the ciphertext
+func VerifyObservation(pk *ecdsa.PublicKey, ciphertext string, iv string, attribute string, supertypeID string, timestamp int64, signature string) error {
+	return verifyECDSA(pk, ObservationSigningPayload(ciphertext, iv, attribute, supertypeID, timestamp), signature)
+}
@@ -0,0 +1,168 @@
+package dynamo
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// maxAccessLogAppendAttempts bounds how often we retry when concurrent appends race for the same head
+const maxAccessLogAppendAttempts = 5
+
+// AppendAccessLog chains an access to a user's data onto the end of their access log
+func (d *Storage) AppendAccessLog(e accesslog.Entry) error {
+	svc := utils.SetupAWSSession()
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+
+	for attempt := 0; attempt < maxAccessLogAppendAttempts; attempt++ {
+		head, err := getAccessLogHead(svc, e.SupertypeID)
+		if err != nil {
+			return err
+		}
+
+		next := accesslog.Next(head, e)
+		entry, err := dynamodbattribute.MarshalMap(next)
+		if err != nil {
+			color.Red("Error marshaling data")
+			return storage.ErrMarshaling
+		}
+		newHead, err := dynamodbattribute.MarshalMap(accesslog.Head{
+			SupertypeID: next.SupertypeID,
+			Sequence:    next.Sequence,
+			Hash:        next.Hash,
+		})
+		if err != nil {
+			color.Red("Error marshaling data")
+			return storage.ErrMarshaling
+		}
+
+		// The head only moves if nobody else appended since we read it, keeping the chain linear
+		headCondition := "attribute_not_exists(supertypeID)"
+		var headNames map[string]*string
+		var headValues map[string]*dynamodb.AttributeValue
+		if head != nil {
+			headCondition = "#sequence = :sequence"
+			headNames = map[string]*string{"#sequence": aws.String("sequence")}
+			headValues = map[string]*dynamodb.AttributeValue{
+				":sequence": {N: aws.String(strconv.FormatInt(head.Sequence, 10))},
+			}
+		}
+
+		_, err = svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: []*dynamodb.TransactWriteItem{
+				{
+					Put: &dynamodb.Put{
+						TableName:           aws.String("access-log"),
+						Item:                entry,
+						ConditionExpression: aws.String("attribute_not_exists(supertypeID)"),
+					},
+				},
+				{
+					Put: &dynamodb.Put{
+						TableName:                 aws.String("access-log-heads"),
+						Item:                      newHead,
+						ConditionExpression:       aws.String(headCondition),
+						ExpressionAttributeNames:  headNames,
+						ExpressionAttributeValues: headValues,
+					},
+				},
+			},
+		})
+		if err == nil {
+			return nil
+		}
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeTransactionCanceledException {
+			color.Red("Failed to write to database")
+			return storage.ErrFailedToWriteDB
+		}
+	}
+
+	color.Red("Gave up appending to access log for %v", e.SupertypeID)
+	return storage.ErrFailedToWriteDB
+}
+
+// GetAccessLog returns all of a user's access log entries in sequence order, along with the log's head
+func (d *Storage) GetAccessLog(supertypeID string) ([]accesslog.Entry, *accesslog.Head, error) {
+	svc := utils.SetupAWSSession()
+
+	head, err := getAccessLogHead(svc, supertypeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := []accesslog.Entry{}
+	err = svc.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String("access-log"),
+		KeyConditionExpression: aws.String("supertypeID = :supertypeID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":supertypeID": {S: aws.String(supertypeID)},
+		},
+		ScanIndexForward: aws.Bool(true),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageEntries []accesslog.Entry
+		if err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageEntries); err != nil {
+			color.Red("Error unmarshaling data")
+			return false
+		}
+		entries = append(entries, pageEntries...)
+		return true
+	})
+	if err != nil {
+		color.Red("Failed to read from database: ", err)
+		return nil, nil, storage.ErrFailedToReadDB
+	}
+
+	return entries, head, nil
+}
+
+// ListAccessLogUsers returns the supertypeID of every user with an access log
+func (d *Storage) ListAccessLogUsers() ([]string, error) {
+	svc := utils.SetupAWSSession()
+
+	var supertypeIDs []string
+	err := svc.ScanPages(&dynamodb.ScanInput{
+		TableName:            aws.String("access-log-heads"),
+		ProjectionExpression: aws.String("supertypeID"),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if item["supertypeID"] != nil && item["supertypeID"].S != nil {
+				supertypeIDs = append(supertypeIDs, *item["supertypeID"].S)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+
+	return supertypeIDs, nil
+}
+
+// getAccessLogHead returns the head of a user's access log, or nil if they have no log yet
+func getAccessLogHead(svc *dynamodb.DynamoDB, supertypeID string) (*accesslog.Head, error) {
+	result, err := GetItemDynamoDB(svc, "access-log-heads", "supertypeID", supertypeID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	head := accesslog.Head{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &head)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return &head, nil
+}
@@ -0,0 +1,109 @@
+package dynamo
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/auditing"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// AppendAuditEvent adds an event to the audit log, refusing to overwrite an existing entry
+func (d *Storage) AppendAuditEvent(e auditing.Event) error {
+	svc := utils.SetupAWSSession()
+
+	av, err := dynamodbattribute.MarshalMap(e)
+	if err != nil {
+		color.Red("Error marshaling data")
+		return storage.ErrMarshaling
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String("audit-log"),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			color.Red("Refusing to overwrite audit event %v", e.ID)
+		}
+		return storage.ErrFailedToWriteDB
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns the audit events matching the filter, oldest first
+func (d *Storage) ListAuditEvents(f auditing.Filter) ([]auditing.Event, error) {
+	svc := utils.SetupAWSSession()
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String("audit-log"),
+	}
+
+	filter, ok := auditFilterCondition(f)
+	if ok {
+		expr, err := expression.NewBuilder().WithFilter(filter).Build()
+		if err != nil {
+			color.Red("Error building expression", err)
+			return nil, err
+		}
+		scanInput.ExpressionAttributeNames = expr.Names()
+		scanInput.ExpressionAttributeValues = expr.Values()
+		scanInput.FilterExpression = expr.Filter()
+	}
+
+	events := []auditing.Event{}
+	err := svc.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageEvents []auditing.Event
+		if err := dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageEvents); err != nil {
+			color.Red("Error unmarshaling data")
+			return false
+		}
+		events = append(events, pageEvents...)
+		return true
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Time < events[j].Time
+	})
+
+	return events, nil
+}
+
+// auditFilterCondition builds a scan filter from the non-empty fields of an audit filter
+func auditFilterCondition(f auditing.Filter) (expression.ConditionBuilder, bool) {
+	var conditions []expression.ConditionBuilder
+	if f.Type != "" {
+		conditions = append(conditions, expression.Name("type").Equal(expression.Value(f.Type)))
+	}
+	if f.Actor != "" {
+		conditions = append(conditions, expression.Name("actor").Equal(expression.Value(f.Actor)))
+	}
+	if f.Since != "" {
+		conditions = append(conditions, expression.Name("time").GreaterThanEqual(expression.Value(f.Since)))
+	}
+	if f.Until != "" {
+		conditions = append(conditions, expression.Name("time").LessThan(expression.Value(f.Until)))
+	}
+
+	if len(conditions) == 0 {
+		return expression.ConditionBuilder{}, false
+	}
+
+	filter := conditions[0]
+	for _, condition := range conditions[1:] {
+		filter = filter.And(condition)
+	}
+	return filter, true
+}
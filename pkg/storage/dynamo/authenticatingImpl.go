@@ -356,10 +356,14 @@ func (d *Storage) AuthorizedLoginUser(u authenticating.UserPassword, apiKey stri
 
 	// Get venor's public key given the vendor's API Key
 	pk, err := ScanDynamoDBWithKeyCondition("vendor", "pk", "apiKeyHash", apiKeyHash)
-	if err != nil || pk == nil {
+	if err != nil {
 		fmt.Println(err)
 		return nil, err
 	}
+	if pk == nil {
+		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
+		return nil, storage.ErrAPIKeyDoesNotMatch
+	}
 
 	pkAlreadyExists, err := ScanDynamoDBWithKeyCondition("user", "pk", "pk", *pk)
 	if err != nil {
@@ -383,3 +387,34 @@ func (d *Storage) AuthorizedLoginUser(u authenticating.UserPassword, apiKey stri
 
 	return &user, nil
 }
+
+// GetVendorPublicKey returns the public key of the vendor owning the given API key
+func (d *Storage) GetVendorPublicKey(apiKey string) (*string, error) {
+	apiKeyHash := utils.GetAPIKeyHash(apiKey)
+
+	pk, err := ScanDynamoDBWithKeyCondition("vendor", "pk", "apiKeyHash", apiKeyHash)
+	if err != nil {
+		return nil, err
+	}
+	if pk == nil {
+		return nil, authenticating.ErrVendorNotFound
+	}
+
+	return pk, nil
+}
+
+// GetVendorUsername returns the username of the vendor owning the given API key
+func (d *Storage) GetVendorUsername(apiKey string) (*string, error) {
+	apiKeyHash := utils.GetAPIKeyHash(apiKey)
+
+	username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "apiKeyHash", apiKeyHash)
+	if err != nil {
+		return nil, err
+	}
+	if username == nil {
+		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
+		return nil, storage.ErrAPIKeyDoesNotMatch
+	}
+
+	return username, nil
+}
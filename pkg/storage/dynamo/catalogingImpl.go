@@ -0,0 +1,186 @@
+package dynamo
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/cataloging"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// ListAttributeDefinitions returns every attribute definition changed through the catalog API
+func (d *Storage) ListAttributeDefinitions() ([]cataloging.Attribute, error) {
+	svc := utils.SetupAWSSession()
+
+	attributes := []cataloging.Attribute{}
+	var unmarshalErr error
+	err := svc.ScanPages(&dynamodb.ScanInput{
+		TableName: aws.String("attributes"),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageAttributes []cataloging.Attribute
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageAttributes)
+		attributes = append(attributes, pageAttributes...)
+		return unmarshalErr == nil
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+	if unmarshalErr != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return attributes, nil
+}
+
+// PutAttributeChange stores the attribute definition a change made, and adds the change to the catalog's changelog, in one transaction
+// It fails if the stored definition is no longer at previousVersion. Attributes only defined in files have no stored definition yet,
+// so any version may replace them
+func (d *Storage) PutAttributeChange(change cataloging.Change, previousVersion int) error {
+	svc := utils.SetupAWSSession()
+
+	definition, err := dynamodbattribute.MarshalMap(change.Definition)
+	if err != nil {
+		color.Red("Error marshaling data")
+		return storage.ErrMarshaling
+	}
+	entry, err := dynamodbattribute.MarshalMap(change)
+	if err != nil {
+		color.Red("Error marshaling data")
+		return storage.ErrMarshaling
+	}
+
+	condition := expression.AttributeNotExists(expression.Name("path")).
+		Or(expression.Name("version").Equal(expression.Value(previousVersion)))
+	expr, err := expression.NewBuilder().WithCondition(condition).Build()
+	if err != nil {
+		color.Red("Error building expression", err)
+		return err
+	}
+
+	_, err = svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:                 aws.String("attributes"),
+					Item:                      definition,
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String("attributeChanges"),
+					Item:      entry,
+				},
+			},
+		},
+	})
+	if err != nil {
+		// Only the definition is conditional, so a failed condition means it changed since previousVersion
+		if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for _, reason := range canceled.CancellationReasons {
+				if aws.StringValue(reason.Code) == "ConditionalCheckFailed" {
+					return cataloging.ErrVersionConflict
+				}
+			}
+		}
+		color.Red("Failed to write to database")
+		return storage.ErrFailedToWriteDB
+	}
+
+	return nil
+}
+
+// ListCatalogChanges returns the catalog's changelog, or one attribute's if path is set, oldest first
+func (d *Storage) ListCatalogChanges(path string) ([]cataloging.Change, error) {
+	svc := utils.SetupAWSSession()
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String("attributeChanges"),
+	}
+	if path != "" {
+		expr, err := expression.NewBuilder().
+			WithFilter(expression.Name("path").Equal(expression.Value(path))).
+			Build()
+		if err != nil {
+			color.Red("Error building expression", err)
+			return nil, err
+		}
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+		input.FilterExpression = expr.Filter()
+	}
+
+	changes := []cataloging.Change{}
+	var unmarshalErr error
+	err := svc.ScanPages(input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageChanges []cataloging.Change
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageChanges)
+		changes = append(changes, pageChanges...)
+		return unmarshalErr == nil
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+	if unmarshalErr != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Time == changes[j].Time {
+			return changes[i].Version < changes[j].Version
+		}
+		return changes[i].Time < changes[j].Time
+	})
+
+	return changes, nil
+}
+
+// ListObservationCounts returns how many observations a vendor has produced for each attribute, by attribute
+func (d *Storage) ListObservationCounts(vendor string) (map[string]int64, error) {
+	svc := utils.SetupAWSSession()
+
+	expr, err := expression.NewBuilder().
+		WithFilter(expression.Name("vendor").Equal(expression.Value(vendor))).
+		Build()
+	if err != nil {
+		color.Red("Error building expression", err)
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	var unmarshalErr error
+	err = svc.ScanPages(&dynamodb.ScanInput{
+		TableName:                 aws.String("attributeStats"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageStats []AttributeStats
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageStats)
+		for _, stats := range pageStats {
+			counts[stats.Attribute] = stats.Observations
+		}
+		return unmarshalErr == nil
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+	if unmarshalErr != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return counts, nil
+}
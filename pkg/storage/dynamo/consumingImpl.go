@@ -1,8 +1,11 @@
 package dynamo
 
 import (
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/fatih/color"
 	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/accesslog"
+	"github.com/super-type/supertype/pkg/authenticating"
 	"github.com/super-type/supertype/pkg/consuming"
 	"github.com/super-type/supertype/pkg/storage"
 )
@@ -11,12 +14,12 @@ import (
 func (d *Storage) Consume(c consuming.ObservationRequest, apiKey string) (*consuming.ObservationResponse, error) {
 	apiKeyHash := utils.GetAPIKeyHash(apiKey)
 	databaseAPIKeyHash, err := ScanDynamoDBWithKeyCondition("vendor", "apiKeyHash", "apiKeyHash", apiKeyHash)
-	if err != nil || databaseAPIKeyHash == nil {
+	if err != nil {
 		return nil, err
 	}
 
 	// Compare requesting API Key with our internal API Key. If they don't match, it's not coming from the vendor
-	if *databaseAPIKeyHash != apiKeyHash {
+	if databaseAPIKeyHash == nil || *databaseAPIKeyHash != apiKeyHash {
 		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
 		return nil, storage.ErrAPIKeyDoesNotMatch
 	}
@@ -29,11 +32,39 @@ func (d *Storage) Consume(c consuming.ObservationRequest, apiKey string) (*consu
 		return nil, err
 	}
 
+	if val.Item == nil {
+		return nil, storage.ErrNoObservationsForEntity
+	}
+	item := Observation{}
+	err = dynamodbattribute.UnmarshalMap(val.Item, &item)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	// Every read of a user's data goes in their access log before it's handed out
+	username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "apiKeyHash", apiKeyHash)
+	if err != nil || username == nil {
+		return nil, authenticating.ErrVendorNotFound
+	}
+	err = d.AppendAccessLog(accesslog.Entry{
+		SupertypeID: c.SupertypeID,
+		Action:      accesslog.ActionConsume,
+		Vendor:      *username,
+		Attribute:   c.Attribute,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	observation := consuming.ObservationResponse{
-		Ciphertext:  *(val.Item["ciphertext"].S),
-		DateAdded:   *(val.Item["dateAdded"].S),
-		PublicKey:   *(val.Item["pk"].S),
-		SupertypeID: *(val.Item["supertypeID"].S),
+		Ciphertext:  item.Ciphertext,
+		DateAdded:   item.DateAdded,
+		PublicKey:   item.PublicKey,
+		SupertypeID: item.SupertypeID,
+		Timestamp:   item.Timestamp,
+		Signature:   item.Signature,
+		Sequence:    item.Sequence,
 	}
 
 	return &observation, nil
@@ -1,188 +1,628 @@
 package dynamo
 
 import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 	"github.com/fatih/color"
+	"github.com/google/uuid"
+	"github.com/super-type/supertype/internal/keys"
 	"github.com/super-type/supertype/internal/utils"
 	"github.com/super-type/supertype/pkg/authenticating"
 	"github.com/super-type/supertype/pkg/dashboard"
 	"github.com/super-type/supertype/pkg/storage"
 )
 
-// ListAttributes returns all observations in the Supertype ecosystem
-func (d *Storage) ListAttributes() ([]string, error) {
+// RegisterWebhook subscribes a vendor's endpoint to an attribute pattern
+func (d *Storage) RegisterWebhook(webhookRequest dashboard.WebhookRequest, apiKey string) (*dashboard.WebhookSecret, error) {
+	apiKeyHash := utils.GetAPIKeyHash(apiKey)
+	username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "apiKeyHash", apiKeyHash)
+	if err != nil {
+		return nil, err
+	}
+	if username == nil {
+		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
+		return nil, storage.ErrAPIKeyDoesNotMatch
+	}
+
 	// Initialize AWS session
 	svc := utils.SetupAWSSession()
-	input := &dynamodb.ListTablesInput{}
-
-	var response []string
-
-	for {
-		// Get list of tables
-		result, err := svc.ListTables(input)
-		if err != nil {
-			if _, ok := err.(awserr.Error); ok {
-				color.Red("Dynamo internal server error")
-				return nil, dashboard.ErrDynamoInternalError
-			}
-			color.Red("Dynamo error")
-			return nil, dashboard.ErrDynamoError
-		}
 
-		for _, n := range result.TableNames {
-			if *n != "poc-todo" && *n != "public-keys" && *n != "vendor" {
-				response = append(response, *n)
-			}
-		}
+	// Every subscription gets its own signing secret, returned to the vendor once here
+	secret, err := keys.GenerateWebhookSecret()
+	if err != nil {
+		color.Red("Failed to generate webhook secret")
+		return nil, keys.ErrFailedToGenerateWebhookSecret
+	}
 
-		// assign the last read tablename as the start for our next call to the ListTables function
-		// the maximum number of table names returned in a call is 100 (default), which requires us to make
-		// multiple calls to the ListTables function to retrieve all table names
-		input.ExclusiveStartTableName = result.LastEvaluatedTableName
+	// Subscriptions stay pending until the endpoint answers a verification challenge
+	subscription := dashboard.Subscription{
+		ID:               uuid.New().String(),
+		Vendor:           *username,
+		AttributePattern: webhookRequest.Attribute,
+		Endpoint:         webhookRequest.Endpoint,
+		Delivery:         *webhookRequest.Delivery,
+		Scope:            *webhookRequest.Scope,
+		SchemaVersion:    webhookRequest.SchemaVersion,
+		Secret:           *secret,
+		Status:           dashboard.StatusPending,
+		CreatedAt:        time.Now().Format(time.RFC3339),
+	}
 
-		if result.LastEvaluatedTableName == nil {
-			break
-		}
+	err = d.putSubscription(svc, subscription)
+	if err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	return &dashboard.WebhookSecret{
+		Endpoint: subscription.Endpoint,
+		Secret:   subscription.Secret,
+		Status:   subscription.Status,
+	}, nil
 }
 
-// RegisterWebhook creates a new webhook on a vendor's request
-func (d *Storage) RegisterWebhook(webhookRequest dashboard.WebhookRequest, apiKey string) error {
+// RotateWebhookSecret replaces a subscription's signing secret, signing with both secrets until the overlap ends
+func (d *Storage) RotateWebhookSecret(rotateRequest dashboard.RotateSecretRequest, apiKey string) (*dashboard.WebhookSecret, error) {
 	apiKeyHash := utils.GetAPIKeyHash(apiKey)
-	databaseAPIKeyHash, err := ScanDynamoDBWithKeyCondition("vendor", "apiKeyHash", "apiKeyHash", apiKeyHash)
-	if err != nil || databaseAPIKeyHash == nil {
-		return err
+	username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "apiKeyHash", apiKeyHash)
+	if err != nil {
+		return nil, err
 	}
-
-	// Compare requesting API Key with our internal API Key. If they don't match, it's not coming from the vendor
-	if *databaseAPIKeyHash != apiKeyHash {
+	if username == nil {
 		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
-		return storage.ErrAPIKeyDoesNotMatch
-	}
-
-	// Parse endpoint, assuming it was validated on client side (or, it'll just throw an error if it's wrong)
-	endpoint := strings.Split(webhookRequest.Endpoint, "/")
-	breakpoint := 0
-	for i := 0; i < len(endpoint); i++ {
-		breakpoint = i
-		// Everything after the /supertype/ qualifier is our Supertype attribute
-		if endpoint[i] == "supertype" {
-			breakpoint++
-			break
-		}
+		return nil, storage.ErrAPIKeyDoesNotMatch
 	}
-	destination := endpoint[breakpoint:]
 
 	// Initialize AWS session
 	svc := utils.SetupAWSSession()
 
-	// Get attribute from subscribers
-	result, err := GetItemDynamoDB(svc, "subscribers", "attribute", destination[0])
+	subscription, err := GetSubscription(svc, rotateRequest.Endpoint)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if subscription == nil {
+		return nil, dashboard.ErrSubscriptionNotFound
+	}
+	if subscription.Vendor != *username {
+		return nil, dashboard.ErrSubscriptionNotOwned
+	}
+
+	secret, err := keys.GenerateWebhookSecret()
+	if err != nil {
+		color.Red("Failed to generate webhook secret")
+		return nil, keys.ErrFailedToGenerateWebhookSecret
+	}
+
+	overlap := dashboard.DefaultSecretOverlap
+	if rotateRequest.OverlapSeconds > 0 {
+		overlap = time.Duration(rotateRequest.OverlapSeconds) * time.Second
+	}
+
+	response := dashboard.WebhookSecret{
+		Endpoint: subscription.Endpoint,
+		Secret:   *secret,
+		Status:   subscription.Status,
+	}
+
+	update := expression.Set(expression.Name("secret"), expression.Value(*secret))
+	if subscription.Secret != "" {
+		response.PreviousSecretExpiresAt = time.Now().Add(overlap).Unix()
+		update = update.
+			Set(expression.Name("previousSecret"), expression.Value(subscription.Secret)).
+			Set(expression.Name("previousSecretExpiresAt"), expression.Value(response.PreviousSecretExpiresAt))
 	}
-	var levels interface{}
-	err = dynamodbattribute.UnmarshalMap(result.Item, &levels)
+
+	_, err = d.updateSubscription(svc, subscription.Endpoint, update)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// UpdateSubscriptionStatus sets a subscription's status, stamping when it was last verified if it's now active
+func (d *Storage) UpdateSubscriptionStatus(endpoint string, status string) error {
+	svc := utils.SetupAWSSession()
+
+	update := expression.Set(expression.Name("status"), expression.Value(status))
+	if status == dashboard.StatusActive {
+		update = update.Set(expression.Name("verifiedAt"), expression.Value(time.Now().Format(time.RFC3339)))
+	}
+
+	_, err := d.updateSubscription(svc, endpoint, update)
+	return err
+}
+
+// ListSubscriptions returns every webhook subscription
+func (d *Storage) ListSubscriptions() ([]dashboard.Subscription, error) {
+	return scanSubscriptions(nil)
+}
+
+// ListVendorSubscriptions returns every webhook subscription belonging to a vendor
+func (d *Storage) ListVendorSubscriptions(username string) ([]dashboard.Subscription, error) {
+	filter := expression.Name("vendor").Equal(expression.Value(username))
+	return scanSubscriptions(&filter)
+}
+
+// MatchSubscriptions returns every subscription whose attribute pattern matches the attribute
+func (d *Storage) MatchSubscriptions(attribute string) ([]dashboard.Subscription, error) {
+	index, err := d.subscriptionIndex()
+	if err != nil {
+		return nil, err
+	}
+	return index.Match(attribute), nil
+}
+
+// UpdateSubscription moves a subscription to a new endpoint, attribute pattern, delivery mode, scope and schema version, keeping its secrets
+// Moving to a new endpoint resets the subscription to pending, since the new endpoint hasn't been verified
+func (d *Storage) UpdateSubscription(subscriptionUpdate dashboard.SubscriptionUpdate) (*dashboard.Subscription, error) {
+	svc := utils.SetupAWSSession()
+
+	if subscriptionUpdate.NewEndpoint == subscriptionUpdate.Endpoint {
+		update := expression.Set(expression.Name("attributePattern"), expression.Value(subscriptionUpdate.AttributePattern)).
+			Set(expression.Name("delivery"), expression.Value(subscriptionUpdate.Delivery)).
+			Set(expression.Name("scope"), expression.Value(subscriptionUpdate.Scope)).
+			Set(expression.Name("schemaVersion"), expression.Value(subscriptionUpdate.SchemaVersion))
+		return d.updateSubscription(svc, subscriptionUpdate.Endpoint, update)
+	}
+
+	subscription, err := GetSubscription(svc, subscriptionUpdate.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, dashboard.ErrSubscriptionNotFound
+	}
+
+	subscription.AttributePattern = subscriptionUpdate.AttributePattern
+	subscription.Delivery = subscriptionUpdate.Delivery
+	subscription.Scope = subscriptionUpdate.Scope
+	subscription.SchemaVersion = subscriptionUpdate.SchemaVersion
+	subscription.Endpoint = subscriptionUpdate.NewEndpoint
+	subscription.Status = dashboard.StatusPending
+	subscription.VerifiedAt = ""
+	err = d.moveSubscription(svc, *subscription, subscriptionUpdate.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// UnregisterWebhook removes a webhook's subscription
+func (d *Storage) UnregisterWebhook(endpoint string) error {
+	svc := utils.SetupAWSSession()
+	return d.deleteSubscription(svc, endpoint)
+}
+
+// SetSubscriptionCredentials stores a subscription's sealed outbound credentials, or removes them if sealed is empty
+func (d *Storage) SetSubscriptionCredentials(endpoint string, sealed string, credentialsType string) (*dashboard.Subscription, error) {
+	svc := utils.SetupAWSSession()
+
+	update := expression.Remove(expression.Name("sealedCredentials")).Remove(expression.Name("credentialsType"))
+	if sealed != "" {
+		update = expression.Set(expression.Name("sealedCredentials"), expression.Value(sealed)).
+			Set(expression.Name("credentialsType"), expression.Value(credentialsType))
+	}
+
+	return d.updateSubscription(svc, endpoint, update)
+}
+
+// SetUserTags replaces the tags a vendor gave one of their users, removing them all if tags is empty
+func (d *Storage) SetUserTags(vendor string, supertypeID string, tags []string) error {
+	svc := utils.SetupAWSSession()
+
+	connected, err := connectedVendors(svc, supertypeID)
 	if err != nil {
 		return err
 	}
+	if !connected[vendor] {
+		return dashboard.ErrUserNotConnected
+	}
 
-	switch destination[0] {
-	case "master-bedroom":
-		var attribute dashboard.MasterBedroom
-		err = dynamodbattribute.UnmarshalMap(result.Item, &attribute)
+	key := map[string]*dynamodb.AttributeValue{
+		"key": {S: aws.String(vendor + "|" + supertypeID)},
+	}
+	if len(tags) == 0 {
+		_, err = svc.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String("userTags"),
+			Key:       key,
+		})
 		if err != nil {
-			return err
+			color.Red("Failed to write to database")
+			return storage.ErrFailedToWriteDB
 		}
+		return nil
+	}
 
-		b, err := json.Marshal(attribute)
-		if err != nil {
-			return err
+	av, err := dynamodbattribute.MarshalMap(UserTags{
+		Key:         vendor + "|" + supertypeID,
+		Vendor:      vendor,
+		SupertypeID: supertypeID,
+		Tags:        tags,
+	})
+	if err != nil {
+		color.Red("Error marshaling data")
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("userTags"),
+		Item:      av,
+	})
+	if err != nil {
+		color.Red("Failed to write to database")
+		return storage.ErrFailedToWriteDB
+	}
+
+	return nil
+}
+
+// getUserTags returns the tags a vendor gave one of their users
+func getUserTags(svc *dynamodb.DynamoDB, vendor string, supertypeID string) ([]string, error) {
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String("userTags"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(vendor + "|" + supertypeID)},
+		},
+	})
+	if err != nil {
+		color.Red("Failed to read from database")
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	userTags := UserTags{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &userTags)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return userTags.Tags, nil
+}
+
+// putSubscription stores a new subscription and indexes it, failing if its endpoint is already subscribed
+func (d *Storage) putSubscription(svc *dynamodb.DynamoDB, subscription dashboard.Subscription) error {
+	av, err := dynamodbattribute.MarshalMap(subscription)
+	if err != nil {
+		color.Red("Error marshaling data")
+		return storage.ErrMarshaling
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String("subscriptions"),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(endpoint)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return dashboard.ErrWebhookAlreadyRegistered
 		}
+		color.Red("Failed to write to database")
+		return storage.ErrFailedToWriteDB
+	}
 
-		err = utils.ValidateNewSubscriberURL(string(b), webhookRequest.Endpoint)
-		if err != nil {
-			return err
+	d.indexPut(subscription)
+	return nil
+}
+
+// updateSubscription changes only the given fields of an existing subscription and indexes the result
+// Other fields are left as they are, so concurrent changes to them aren't lost, and a deleted subscription isn't recreated
+func (d *Storage) updateSubscription(svc *dynamodb.DynamoDB, endpoint string, update expression.UpdateBuilder) (*dashboard.Subscription, error) {
+	expr, err := expression.NewBuilder().
+		WithUpdate(update).
+		WithCondition(expression.AttributeExists(expression.Name("endpoint"))).
+		Build()
+	if err != nil {
+		color.Red("Error building expression", err)
+		return nil, err
+	}
+
+	result, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("subscriptions"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"endpoint": {S: aws.String(endpoint)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, dashboard.ErrSubscriptionNotFound
 		}
+		color.Red("Failed to write to database")
+		return nil, storage.ErrFailedToWriteDB
+	}
 
-		urls := GetSubscribersFromEndpoint(destination, levels)
-		updatedAttribute, err := utils.AppendToSubscribers(string(b), urls, webhookRequest.Endpoint)
-		if err != nil {
-			return err
+	subscription := dashboard.Subscription{}
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, &subscription)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+	d.indexPut(subscription)
+
+	return &subscription, nil
+}
+
+// moveSubscription stores a subscription under its new endpoint and removes the record at its old one in one transaction,
+// so the subscription is never stored twice
+func (d *Storage) moveSubscription(svc *dynamodb.DynamoDB, subscription dashboard.Subscription, oldEndpoint string) error {
+	av, err := dynamodbattribute.MarshalMap(subscription)
+	if err != nil {
+		color.Red("Error marshaling data")
+		return storage.ErrMarshaling
+	}
+
+	_, err = svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String("subscriptions"),
+					Item:                av,
+					ConditionExpression: aws.String("attribute_not_exists(endpoint)"),
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String("subscriptions"),
+					Key: map[string]*dynamodb.AttributeValue{
+						"endpoint": {S: aws.String(oldEndpoint)},
+					},
+					ConditionExpression: aws.String("attribute_exists(endpoint)"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		// Reasons are in the order of the items, so the put failing means the new endpoint is taken
+		if canceled, ok := err.(*dynamodb.TransactionCanceledException); ok && len(canceled.CancellationReasons) == 2 {
+			if aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+				return dashboard.ErrWebhookAlreadyRegistered
+			}
+			if aws.StringValue(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+				return dashboard.ErrSubscriptionNotFound
+			}
 		}
+		color.Red("Failed to write to database")
+		return storage.ErrFailedToWriteDB
+	}
 
-		resp := dashboard.MasterBedroom{}
-		err = json.Unmarshal([]byte(*updatedAttribute), &resp)
-		if err != nil {
-			fmt.Println(err)
-			return err
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	if d.index != nil {
+		d.index.Remove(oldEndpoint)
+		d.index.Put(subscription)
+	}
+	return nil
+}
+
+// deleteSubscription removes a subscription's record and takes it out of the index
+func (d *Storage) deleteSubscription(svc *dynamodb.DynamoDB, endpoint string) error {
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String("subscriptions"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"endpoint": {S: aws.String(endpoint)},
+		},
+	})
+	if err != nil {
+		color.Red("Failed to write to database")
+		return storage.ErrFailedToWriteDB
+	}
+
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	if d.index != nil {
+		d.index.Remove(endpoint)
+	}
+	return nil
+}
+
+// subscriptionIndex returns the subscription index, loading it from the subscriptions table when it's missing or stale
+func (d *Storage) subscriptionIndex() (*dashboard.Index, error) {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+
+	if d.index != nil && time.Since(d.indexLoadedAt) < dashboard.IndexRefreshInterval {
+		return d.index, nil
+	}
+
+	subscriptions, err := scanSubscriptions(nil)
+	if err != nil {
+		// A stale index is better than dropping every delivery while the table is unreachable
+		if d.index != nil {
+			color.Yellow("Failed to refresh subscription index, using the one loaded at %v", d.indexLoadedAt)
+			return d.index, nil
 		}
+		return nil, err
+	}
+
+	d.index = dashboard.NewIndex(subscriptions)
+	d.indexLoadedAt = time.Now()
+	return d.index, nil
+}
+
+// indexPut keeps a loaded subscription index in step with a subscription written by this instance
+func (d *Storage) indexPut(subscription dashboard.Subscription) {
+	d.indexMu.Lock()
+	defer d.indexMu.Unlock()
+	if d.index != nil {
+		d.index.Put(subscription)
+	}
+}
 
-		err = PutItemInDynamoDB(resp, "subscribers", svc)
+// scanSubscriptions returns every subscription matching the filter, or every subscription if it's nil
+func scanSubscriptions(filter *expression.ConditionBuilder) ([]dashboard.Subscription, error) {
+	svc := utils.SetupAWSSession()
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String("subscriptions"),
+	}
+	if filter != nil {
+		expr, err := expression.NewBuilder().WithFilter(*filter).Build()
 		if err != nil {
-			fmt.Println(err)
-			return err
+			color.Red("Error building expression", err)
+			return nil, err
 		}
-	// This could get ugly, fast... we should try to think of a way to do this more programmatically
-	case "living-room":
-	case "laundry-room":
-	case "kitchen":
-	case "kids-bedroom":
-	case "guest-bedroom":
-	case "garage":
-	case "bathroom":
-	default:
-		return errors.New("Invalid attribute")
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+		input.FilterExpression = expr.Filter()
 	}
 
-	username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "apiKeyHash", apiKeyHash)
+	subscriptions := []dashboard.Subscription{}
+	var unmarshalErr error
+	err := svc.ScanPages(input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageSubscriptions []dashboard.Subscription
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageSubscriptions)
+		subscriptions = append(subscriptions, pageSubscriptions...)
+		return unmarshalErr == nil
+	})
 	if err != nil {
-		return err
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
 	}
-	result, err = GetItemDynamoDB(svc, "vendor", "username", *username)
-	if err != nil {
-		return err
+	if unmarshalErr != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
 	}
 
-	vendor := authenticating.CreateVendor{}
-	err = dynamodbattribute.UnmarshalMap(result.Item, &vendor)
+	return subscriptions, nil
+}
+
+// MigrateLegacyWebhooks turns webhook URLs still listed on vendor records into subscription records,
+// backfilling ids and attribute patterns on subscriptions created before they had them
+// Endpoints without a record get a new secret, which their vendor receives by rotating it.
+// Webhooks whose attribute can't be worked out are left on the vendor record and returned as skipped
+func (d *Storage) MigrateLegacyWebhooks() ([]dashboard.Subscription, []SkippedWebhook, error) {
+	svc := utils.SetupAWSSession()
+
+	var vendors []authenticating.CreateVendor
+	var unmarshalErr error
+	err := svc.ScanPages(&dynamodb.ScanInput{
+		TableName:            aws.String("vendor"),
+		ProjectionExpression: aws.String("username, webhooks"),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageVendors []authenticating.CreateVendor
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageVendors)
+		vendors = append(vendors, pageVendors...)
+		return unmarshalErr == nil
+	})
 	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, nil, storage.ErrFailedToReadDB
+	}
+	if unmarshalErr != nil {
 		color.Red("Error unmarshaling data")
-		return storage.ErrUnmarshaling
+		return nil, nil, storage.ErrUnmarshaling
 	}
 
-	updatedWebhooks := append(vendor.Webhooks, webhookRequest.Endpoint)
+	migrated := []dashboard.Subscription{}
+	skipped := []SkippedWebhook{}
+	for _, vendor := range vendors {
+		remaining := []string{}
+		for _, endpoint := range vendor.Webhooks {
+			subscription, err := GetSubscription(svc, endpoint)
+			if err != nil {
+				return migrated, skipped, err
+			}
+			isNew := subscription == nil
+			if isNew {
+				secret, err := keys.GenerateWebhookSecret()
+				if err != nil {
+					return migrated, skipped, keys.ErrFailedToGenerateWebhookSecret
+				}
+				subscription = &dashboard.Subscription{
+					Vendor:    vendor.Username,
+					Endpoint:  endpoint,
+					Secret:    *secret,
+					Status:    dashboard.StatusPending,
+					CreatedAt: time.Now().Format(time.RFC3339),
+				}
+			}
+			if subscription.ID != "" && subscription.AttributePattern != "" {
+				continue
+			}
 
-	updatedVendor := authenticating.CreateVendor{
-		FirstName:      vendor.FirstName,
-		LastName:       vendor.LastName,
-		Email:          vendor.Email,
-		BusinessName:   vendor.BusinessName,
-		Username:       vendor.Username,
-		PublicKey:      vendor.PublicKey,
-		APIKeyHash:     apiKeyHash,
-		SupertypeID:    vendor.SupertypeID,
-		AccountBalance: vendor.AccountBalance,
-		Webhooks:       updatedWebhooks,
-	}
+			if subscription.ID == "" {
+				subscription.ID = uuid.New().String()
+			}
+			if subscription.AttributePattern == "" {
+				subscription.AttributePattern, err = dashboard.AttributeFromEndpoint(endpoint)
+				if err != nil {
+					color.Yellow("Skipping webhook %v of %v: %v", endpoint, vendor.Username, err)
+					skipped = append(skipped, SkippedWebhook{Vendor: vendor.Username, Endpoint: endpoint, Reason: err.Error()})
+					remaining = append(remaining, endpoint)
+					continue
+				}
+			}
 
-	// TODO this needs to be an atomic transaction. If we can't add the URL to vendor, we need to remove it from "subscribers"
-	// The URL can't exist in one place but not the other... maybe this is bad practice without a single source of truth?
-	err = PutItemInDynamoDB(updatedVendor, "vendor", svc)
-	if err != nil {
-		fmt.Println(err)
-		return err
+			if isNew {
+				err = d.putSubscription(svc, *subscription)
+			} else {
+				update := expression.Set(expression.Name("id"), expression.Value(subscription.ID)).
+					Set(expression.Name("attributePattern"), expression.Value(subscription.AttributePattern))
+				subscription, err = d.updateSubscription(svc, endpoint, update)
+			}
+			if err == dashboard.ErrWebhookAlreadyRegistered || err == dashboard.ErrSubscriptionNotFound {
+				// The endpoint was registered or unregistered since it was read, which has taken care of it
+				color.Yellow("Webhook %v of %v changed during the migration, leaving it as it is", endpoint, vendor.Username)
+				continue
+			}
+			if err != nil {
+				return migrated, skipped, err
+			}
+			migrated = append(migrated, *subscription)
+		}
+
+		if len(vendor.Webhooks) == 0 {
+			continue
+		}
+
+		// Subscriptions are the only record of a migrated webhook from now on, so only skipped webhooks stay on the vendor.
+		// The list is only replaced if it hasn't changed since it was read
+		update := expression.Remove(expression.Name("webhooks"))
+		if len(remaining) > 0 {
+			update = expression.Set(expression.Name("webhooks"), expression.Value(remaining))
+		}
+		expr, err := expression.NewBuilder().
+			WithUpdate(update).
+			WithCondition(expression.Name("webhooks").Equal(expression.Value(vendor.Webhooks))).
+			Build()
+		if err != nil {
+			color.Red("Error building expression", err)
+			return migrated, skipped, err
+		}
+		_, err = svc.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName: aws.String("vendor"),
+			Key: map[string]*dynamodb.AttributeValue{
+				"username": {S: aws.String(vendor.Username)},
+			},
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				color.Yellow("Webhooks of %v changed during the migration, run it again to finish them", vendor.Username)
+				continue
+			}
+			color.Red("Failed to write to database")
+			return migrated, skipped, storage.ErrFailedToWriteDB
+		}
 	}
 
-	return nil
+	return migrated, skipped, nil
 }
@@ -0,0 +1,177 @@
+package dynamo
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/delivering"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// GetWebhookSubscription returns the webhook subscription for an endpoint, or nil if it has none
+func (d *Storage) GetWebhookSubscription(endpoint string) (*dashboard.Subscription, error) {
+	svc := utils.SetupAWSSession()
+	return GetSubscription(svc, endpoint)
+}
+
+// PutDeadLetter stores a webhook delivery that ran out of attempts
+func (d *Storage) PutDeadLetter(deadLetter delivering.DeadLetter) error {
+	svc := utils.SetupAWSSession()
+	return PutItemInDynamoDB(deadLetter, "webhook-dead-letters", svc)
+}
+
+// PutAttempt stores one attempt at sending a webhook delivery
+func (d *Storage) PutAttempt(attempt delivering.Attempt) error {
+	svc := utils.SetupAWSSession()
+	return PutItemInDynamoDB(attempt, "webhook-attempts", svc)
+}
+
+// ListAttempts returns a vendor's webhook attempts matching the filter, oldest first
+func (d *Storage) ListAttempts(vendor string, f delivering.Filter) ([]delivering.Attempt, error) {
+	attempts := []delivering.Attempt{}
+	err := scanDeliveryTable("webhook-attempts", deliveryFilterCondition(vendor, "time", f), &attempts)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(attempts, func(i, j int) bool {
+		return attempts[i].Time < attempts[j].Time
+	})
+	return attempts, nil
+}
+
+// GetAttempts returns every attempt at a single delivery, in order
+func (d *Storage) GetAttempts(deliveryID string) ([]delivering.Attempt, error) {
+	svc := utils.SetupAWSSession()
+
+	result, err := svc.Query(&dynamodb.QueryInput{
+		TableName:              aws.String("webhook-attempts"),
+		KeyConditionExpression: aws.String("deliveryID = :deliveryID"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deliveryID": {S: aws.String(deliveryID)},
+		},
+		ScanIndexForward: aws.Bool(true),
+	})
+	if err != nil {
+		color.Red("Failed to read from database: ", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+
+	attempts := []delivering.Attempt{}
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &attempts)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return attempts, nil
+}
+
+// ListDeadLetters returns a vendor's dead-lettered deliveries matching the filter, oldest first
+func (d *Storage) ListDeadLetters(vendor string, f delivering.Filter) ([]delivering.DeadLetter, error) {
+	deadLetters := []delivering.DeadLetter{}
+	err := scanDeliveryTable("webhook-dead-letters", deliveryFilterCondition(vendor, "failedAt", f), &deadLetters)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].FailedAt < deadLetters[j].FailedAt
+	})
+	return deadLetters, nil
+}
+
+// GetDeadLetter returns a dead-lettered delivery, or nil if there is none with that ID
+func (d *Storage) GetDeadLetter(deliveryID string) (*delivering.DeadLetter, error) {
+	svc := utils.SetupAWSSession()
+
+	result, err := GetItemDynamoDB(svc, "webhook-dead-letters", "id", deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	deadLetter := delivering.DeadLetter{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &deadLetter)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return &deadLetter, nil
+}
+
+// DeleteDeadLetter removes a dead-lettered delivery
+func (d *Storage) DeleteDeadLetter(deliveryID string) error {
+	svc := utils.SetupAWSSession()
+
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String("webhook-dead-letters"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(deliveryID)},
+		},
+	})
+	if err != nil {
+		color.Red("Failed to delete from database")
+		return storage.ErrFailedToWriteDB
+	}
+
+	return nil
+}
+
+// deliveryFilterCondition builds a scan filter for one vendor's delivery records, bounding timeAttribute by the filter
+func deliveryFilterCondition(vendor string, timeAttribute string, f delivering.Filter) expression.ConditionBuilder {
+	filter := expression.Name("vendor").Equal(expression.Value(vendor))
+	if f.Endpoint != "" {
+		filter = filter.And(expression.Name("endpoint").Equal(expression.Value(f.Endpoint)))
+	}
+	if f.Since != "" {
+		filter = filter.And(expression.Name(timeAttribute).GreaterThanEqual(expression.Value(f.Since)))
+	}
+	if f.Until != "" {
+		filter = filter.And(expression.Name(timeAttribute).LessThan(expression.Value(f.Until)))
+	}
+	return filter
+}
+
+// scanDeliveryTable scans every page of a table with the given filter, unmarshaling the items into out
+func scanDeliveryTable(table string, filter expression.ConditionBuilder, out interface{}) error {
+	svc := utils.SetupAWSSession()
+
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		color.Red("Error building expression", err)
+		return err
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	err = svc.ScanPages(&dynamodb.ScanInput{
+		TableName:                 aws.String(table),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return storage.ErrFailedToReadDB
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(items, out)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return storage.ErrUnmarshaling
+	}
+
+	return nil
+}
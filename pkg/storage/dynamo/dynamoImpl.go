@@ -1,7 +1,16 @@
 package dynamo
 
+import (
+	"sync"
+	"time"
+
+	"github.com/super-type/supertype/pkg/dashboard"
+)
+
 // Storage keeps data in dynamo
 type Storage struct {
-	Vendor
-	Observation
+	// Subscriptions are indexed in memory so produces don't scan the subscriptions table
+	indexMu       sync.Mutex
+	index         *dashboard.Index
+	indexLoadedAt time.Time
 }
@@ -26,4 +26,30 @@ type Observation struct {
 	DateAdded   string `json:"dateAdded"`
 	PublicKey   string `json:"pk"`
 	SupertypeID string `json:"supertypeID"`
+	Timestamp   int64  `json:"timestamp"`
+	Signature   string `json:"signature"`
+	Sequence    int64  `json:"sequence"`
+}
+
+// UserTags are the tags a vendor gave one of their users, keyed by "<VENDOR>|<SUPERTYPE ID>"
+type UserTags struct {
+	Key         string   `json:"key"`
+	Vendor      string   `json:"vendor"`
+	SupertypeID string   `json:"supertypeID"`
+	Tags        []string `json:"tags"`
+}
+
+// AttributeStats counts the observations a vendor produced for an attribute, keyed by "<VENDOR>|<ATTRIBUTE>"
+type AttributeStats struct {
+	Key          string `json:"key"`
+	Vendor       string `json:"vendor"`
+	Attribute    string `json:"attribute"`
+	Observations int64  `json:"observations"`
+}
+
+// SkippedWebhook is a webhook listed on a vendor record that couldn't be migrated to a subscription, so was left in place
+type SkippedWebhook struct {
+	Vendor   string
+	Endpoint string
+	Reason   string
 }
@@ -0,0 +1,82 @@
+package dynamo
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/idempotency"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// ReserveIdempotencyKey stores the record if its key is unused or expired, otherwise returns the record already stored
+func (d *Storage) ReserveIdempotencyKey(record idempotency.Record) (*idempotency.Record, error) {
+	svc := utils.SetupAWSSession()
+
+	av, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		color.Red("Error marshaling data")
+		return nil, storage.ErrMarshaling
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("idempotency-keys"),
+		Item:      av,
+		// Expired keys may linger until DynamoDB's TTL sweeps them, so treat them as absent
+		ConditionExpression: aws.String("attribute_not_exists(idempotencyKey) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+		},
+	})
+	if err == nil {
+		return nil, nil
+	}
+
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		color.Red("Failed to write to database")
+		return nil, err
+	}
+
+	result, err := GetItemDynamoDB(svc, "idempotency-keys", "idempotencyKey", record.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := idempotency.Record{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &existing)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	return &existing, nil
+}
+
+// PutIdempotencyRecord stores a completed idempotency record
+func (d *Storage) PutIdempotencyRecord(record idempotency.Record) error {
+	svc := utils.SetupAWSSession()
+	return PutItemInDynamoDB(record, "idempotency-keys", svc)
+}
+
+// DeleteIdempotencyKey removes an idempotency key so it can be reused
+func (d *Storage) DeleteIdempotencyKey(key string) error {
+	svc := utils.SetupAWSSession()
+
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String("idempotency-keys"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"idempotencyKey": {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		color.Red("Failed to delete from database")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package dynamo
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/fatih/color"
+	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/notifying"
+	"github.com/super-type/supertype/pkg/storage"
+)
+
+// PutNotification stores a notification for a vendor
+func (d *Storage) PutNotification(n notifying.Notification) error {
+	svc := utils.SetupAWSSession()
+	return PutItemInDynamoDB(n, "notifications", svc)
+}
+
+// ListNotifications returns every notification for a vendor, newest first
+func (d *Storage) ListNotifications(vendor string) ([]notifying.Notification, error) {
+	svc := utils.SetupAWSSession()
+
+	expr, err := expression.NewBuilder().
+		WithFilter(expression.Name("vendor").Equal(expression.Value(vendor))).
+		Build()
+	if err != nil {
+		color.Red("Error building expression", err)
+		return nil, err
+	}
+
+	notifications := []notifying.Notification{}
+	var unmarshalErr error
+	err = svc.ScanPages(&dynamodb.ScanInput{
+		TableName:                 aws.String("notifications"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageNotifications []notifying.Notification
+		unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageNotifications)
+		notifications = append(notifications, pageNotifications...)
+		return unmarshalErr == nil
+	})
+	if err != nil {
+		color.Red("Error scanning", err)
+		return nil, storage.ErrFailedToReadDB
+	}
+	if unmarshalErr != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].Time > notifications[j].Time
+	})
+
+	return notifications, nil
+}
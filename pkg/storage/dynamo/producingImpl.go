@@ -1,40 +1,57 @@
 package dynamo
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/fatih/color"
+	"github.com/google/uuid"
 	"github.com/super-type/supertype/internal/utils"
 	"github.com/super-type/supertype/pkg/authenticating"
+	"github.com/super-type/supertype/pkg/delivering"
 	"github.com/super-type/supertype/pkg/producing"
 	"github.com/super-type/supertype/pkg/storage"
 )
 
+// maxSequenceAttempts is how many times an observation is stored before giving up, when concurrent observations
+// of the same user's attribute keep taking its sequence number first
+const maxSequenceAttempts = 5
+
 // Produce produces encyrpted data to Supertype
-func (d *Storage) Produce(o producing.ObservationRequest, apiKey string) error {
+// It returns a delivery for every webhook subscribed to the observation, leaving sending them to the caller,
+// along with the deliveries whose scope couldn't be checked, which should be dead-lettered instead
+// Once the observation is stored nothing fails the request, since retrying it would be rejected as a replay
+func (d *Storage) Produce(o producing.ObservationRequest, apiKey string) ([]delivering.Delivery, []delivering.Delivery, error) {
 	apiKeyHash := utils.GetAPIKeyHash(apiKey)
 	databaseAPIKeyHash, err := ScanDynamoDBWithKeyCondition("vendor", "apiKeyHash", "apiKeyHash", apiKeyHash)
-	if err != nil || databaseAPIKeyHash == nil {
-		return err
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Compare requesting API Key with our internal API Key. If they don't match, it's not coming from the vendor
+	if databaseAPIKeyHash == nil || *databaseAPIKeyHash != apiKeyHash {
+		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
+		return nil, nil, storage.ErrAPIKeyDoesNotMatch
 	}
 
 	pk, err := ScanDynamoDBWithKeyCondition("vendor", "pk", "apiKeyHash", apiKeyHash)
 	if err != nil || pk == nil {
 		fmt.Println(err)
-		return err
+		return nil, nil, err
 	}
 
-	// Compare requesting API Key with our internal API Key. If they don't match, it's not coming from the vendor
-	if *databaseAPIKeyHash != apiKeyHash {
+	// Subscriptions can ask for observations from particular producers only
+	producer, err := ScanDynamoDBWithKeyCondition("vendor", "username", "apiKeyHash", apiKeyHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if producer == nil {
 		color.Red("!!! Vendor secret key hashes do no match - potential malicious attempt !!!")
-		return storage.ErrAPIKeyDoesNotMatch
+		return nil, nil, storage.ErrAPIKeyDoesNotMatch
 	}
 
 	// Initialize AWS session
@@ -43,113 +60,311 @@ func (d *Storage) Produce(o producing.ObservationRequest, apiKey string) error {
 	// Get current time
 	currentTime := time.Now()
 
+	// Reject replays early, the transaction storing the observation checks the nonce again
+	nonceKey, expiresAt := observationNonce(apiKey, o)
+	used, err := nonceUsed(svc, nonceKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if used {
+		color.Red("!!! Observation nonce reused - potential replay attempt !!!")
+		return nil, nil, producing.ErrReplayedObservation
+	}
+
 	// Create an observation to upload to DynamoDB
-	d.Observation = Observation{
+	observation := Observation{
 		Ciphertext:  o.Ciphertext + "|" + o.IV + "|" + o.Attribute,
 		DateAdded:   currentTime.Format("2006-01-02 15:04:05.000000000"),
 		PublicKey:   *pk,
 		SupertypeID: o.SupertypeID,
+		Timestamp:   o.Timestamp,
+		Signature:   o.Signature,
 	}
 
-	// Upload new observation to DynamoDB
-	err = PutItemInDynamoDB(d.Observation, o.Attribute, svc)
+	// Upload new observation to DynamoDB along with its nonce and sequence number, so a failed write can be retried
+	// with the same nonce and doesn't leave a gap in the sequence
+	sequence, err := putObservation(svc, o.Attribute, observation, nonceKey, expiresAt)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	observation.Sequence = sequence
 
-	// 1. Associate supertypeID with user, and get all vendors associated with that user
-	username, err := ScanDynamoDBWithKeyCondition("user", "username", "supertypeID", o.SupertypeID)
+	// 1. Get the usernames of the vendors associated with the user, since only they may receive the user's data
+	// Without them nobody can be sent the observation, but it's stored and can still be consumed
+	connected, err := connectedVendors(svc, o.SupertypeID)
 	if err != nil {
-		return err
+		color.Red("Failed to find vendors connected to %v, no webhooks will receive observation %v: %v", o.SupertypeID, sequence, err)
+		return nil, nil, nil
 	}
-	result, err := GetItemDynamoDB(svc, "user", "username", *username)
+
+	// The observation is already stored, so failing to count it only leaves the vendor's attribute listing short
+	err = countObservation(svc, *producer, o.Attribute)
 	if err != nil {
-		return err
+		color.Red("Failed to count observation of %v by %v: %v", o.Attribute, *producer, err)
 	}
-	user := authenticating.UserWithVendors{}
-	err = dynamodbattribute.UnmarshalMap(result.Item, &user)
+
+	// 2. Find every subscription to the published attribute (like every subscription to master-bedroom/lights/status)
+	subscriptions, err := d.MatchSubscriptions(o.Attribute)
 	if err != nil {
-		color.Red("Error unmarshaling data")
-		return storage.ErrUnmarshaling
+		color.Red("Failed to find subscriptions to %v, no webhooks will receive observation %v: %v", o.Attribute, sequence, err)
+		return nil, nil, nil
 	}
-	var webhooks []string
 
-	// 2. Get all URLs associated with those vendors associated with the given user
-	for _, vendor := range user.Vendors {
-		username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "pk", vendor)
-		if err != nil {
-			return err
+	// 3. If a subscription belongs to one of the vendors associated with the given user, queue a Webhook POST request
+	event := delivering.ObservationEvent{
+		ID:          uuid.New().String(),
+		OccurredAt:  currentTime,
+		Attribute:   o.Attribute,
+		SupertypeID: o.SupertypeID,
+		Producer:    *producer,
+		Sequence:    sequence,
+		Ciphertext:  o.Ciphertext,
+		IV:          o.IV,
+		PublicKey:   *pk,
+		Signature:   o.Signature,
+		Timestamp:   o.Timestamp,
+	}
+
+	var deliveries, unscoped []delivering.Delivery
+	userTags := map[string][]string{}
+	failedTags := map[string]bool{}
+	for _, subscription := range subscriptions {
+		// Only endpoints that proved they belong to the vendor receive data
+		if !connected[subscription.Vendor] || !subscription.Active() {
+			continue
 		}
-		result, err := GetItemDynamoDB(svc, "vendor", "username", *username)
+
+		// The user's tags are only looked up once per vendor, and only if a scope filters on them
+		// If they can't be, the scope can't be checked, so the delivery is held back to be replayed
+		scoped := true
+		if subscription.Scope.FiltersUserTags() {
+			if _, ok := userTags[subscription.Vendor]; !ok && !failedTags[subscription.Vendor] {
+				userTags[subscription.Vendor], err = getUserTags(svc, subscription.Vendor, o.SupertypeID)
+				if err != nil {
+					color.Red("Failed to get tags %v gave %v: %v", subscription.Vendor, o.SupertypeID, err)
+					failedTags[subscription.Vendor] = true
+				}
+			}
+			scoped = !failedTags[subscription.Vendor]
+		}
+		if scoped && !subscription.Scope.Admits(o.SupertypeID, *producer, userTags[subscription.Vendor]) {
+			continue
+		}
+
+		// Each subscription gets the event in the schema version it's pinned to
+		requestBody, err := event.Body(subscription.EventSchemaVersion())
 		if err != nil {
-			return err
+			color.Red("Failed to marshal event for %v: %v", subscription.Endpoint, err)
+			continue
+		}
+
+		delivery := delivering.Delivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: subscription.ID,
+			Endpoint:       subscription.Endpoint,
+			Vendor:         subscription.Vendor,
+			SupertypeID:    o.SupertypeID,
+			Attribute:      o.Attribute,
+			Sequence:       observation.Sequence,
+			Mode:           subscription.Delivery,
+			Body:           requestBody,
+			CreatedAt:      currentTime,
 		}
-		for _, url := range result.Item["webhooks"].L {
-			webhooks = append(webhooks, *url.S)
+		if !scoped {
+			unscoped = append(unscoped, delivery)
+			continue
 		}
+		deliveries = append(deliveries, delivery)
 	}
 
-	// 3. Iterate through all URLs for the published attribute (like all URLs for master-bedroom/lights/status)
-	var webhookURLs []string
-	destination := strings.Split(o.Attribute, "/")
+	return deliveries, unscoped, nil
+}
+
+// observationNonce returns the key an observation's nonce is stored under, and when it can be forgotten
+// Nonces are scoped per vendor so one vendor can't burn another's, and only need remembering
+// for as long as their timestamp would still be accepted
+func observationNonce(apiKey string, o producing.ObservationRequest) (string, time.Time) {
+	return utils.GetAPIKeyHash(apiKey) + ":" + o.Nonce, time.Unix(o.Timestamp, 0).Add(producing.ReplayWindow)
+}
 
-	// Get attribute from subscribers
-	result, err = GetItemDynamoDB(svc, "subscribers", "attribute", destination[0])
+// nonceUsed reports whether a nonce is stored and unexpired
+func nonceUsed(svc *dynamodb.DynamoDB, key string) (bool, error) {
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String("nonces"),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"nonce": {S: aws.String(key)},
+		},
+	})
 	if err != nil {
-		return err
+		color.Red("Failed to read from database")
+		return false, storage.ErrFailedToReadDB
 	}
-	var levels interface{}
-	err = dynamodbattribute.UnmarshalMap(result.Item, &levels)
-	if err != nil {
-		return err
+	if result.Item == nil || result.Item["expiresAt"] == nil {
+		return false, nil
 	}
 
-	if levels == nil {
-		return errors.New("Couldn't find attribute")
+	// Expired nonces may linger until DynamoDB's TTL sweeps them, so treat them as absent
+	expiresAt, err := strconv.ParseInt(aws.StringValue(result.Item["expiresAt"].N), 10, 64)
+	if err != nil {
+		return false, err
 	}
+	return expiresAt >= time.Now().Unix(), nil
+}
 
-	urls := GetSubscribersFromEndpoint(destination, levels)
-	for _, url := range urls {
-		webhookURLs = append(webhookURLs, url.(string))
-	}
+// putObservation numbers an observation and stores it with its nonce in one transaction, returning its sequence number
+// It fails if the nonce is already stored and unexpired, and tries again if another observation took the number first
+func putObservation(svc *dynamodb.DynamoDB, attribute string, observation Observation, nonceKey string, expiresAt time.Time) (int64, error) {
+	sequenceKey := observation.SupertypeID + "|" + attribute
+	for i := 0; i < maxSequenceAttempts; i++ {
+		current, err := currentSequence(svc, sequenceKey)
+		if err != nil {
+			return 0, err
+		}
+		observation.Sequence = current + 1
 
-	// 4. If a URL matches the URLs associated with the vendors that are associated with a given user, send a Webhook POST request
-	for _, webhookURL := range webhookURLs {
-		if utils.Contains(webhooks, webhookURL) {
-			color.Cyan("Sending POST to %v\n", webhookURL)
+		item, err := dynamodbattribute.MarshalMap(observation)
+		if err != nil {
+			color.Red("Error marshaling data")
+			return 0, storage.ErrMarshaling
+		}
 
-			requestBody, err := json.Marshal(map[string]string{
-				"dateAdded":   currentTime.Format("2006-01-02 15:04:05.000000000"),
-				"ciphertext":  d.Observation.Ciphertext,
-				"pk":          d.Observation.PublicKey,
-				"supertypeID": d.Observation.SupertypeID,
-			})
-			if err != nil {
-				color.Red("Error marshaling data")
-				return err
-			}
+		_, err = svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: []*dynamodb.TransactWriteItem{
+				{
+					Put: &dynamodb.Put{
+						TableName: aws.String("nonces"),
+						Item: map[string]*dynamodb.AttributeValue{
+							"nonce":     {S: aws.String(nonceKey)},
+							"expiresAt": {N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))},
+						},
+						ConditionExpression: aws.String("attribute_not_exists(nonce) OR expiresAt < :now"),
+						ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+							":now": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+						},
+					},
+				},
+				{
+					Update: &dynamodb.Update{
+						TableName: aws.String("sequences"),
+						Key: map[string]*dynamodb.AttributeValue{
+							"key": {S: aws.String(sequenceKey)},
+						},
+						UpdateExpression:         aws.String("SET #sequence = :next"),
+						ConditionExpression:      aws.String("attribute_not_exists(#sequence) OR #sequence = :current"),
+						ExpressionAttributeNames: map[string]*string{"#sequence": aws.String("sequence")},
+						ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+							":current": {N: aws.String(strconv.FormatInt(current, 10))},
+							":next":    {N: aws.String(strconv.FormatInt(observation.Sequence, 10))},
+						},
+					},
+				},
+				{
+					Put: &dynamodb.Put{
+						TableName: aws.String(attribute),
+						Item:      item,
+					},
+				},
+			},
+		})
+		if err == nil {
+			return observation.Sequence, nil
+		}
 
-			client := &http.Client{}
-			req, err := http.NewRequest("POST", webhookURL, bytes.NewReader(requestBody))
-			if err != nil {
-				fmt.Println(err)
-				return err
-			}
-			req.Header.Add("Content-Type", "application/json")
-			req.Header.Add("X-Supertype-Signature", apiKeyHash) // Send API Key Hash as signature, so vendors can verify it's from us
+		// Reasons are listed in the order of the items, so the first is the nonce and the second the sequence
+		canceled, ok := err.(*dynamodb.TransactionCanceledException)
+		if !ok || len(canceled.CancellationReasons) < 2 {
+			color.Red("Failed to write to database")
+			return 0, storage.ErrFailedToWriteDB
+		}
+		if aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			color.Red("!!! Observation nonce reused - potential replay attempt !!!")
+			return 0, producing.ErrReplayedObservation
+		}
+		if aws.StringValue(canceled.CancellationReasons[1].Code) != "ConditionalCheckFailed" {
+			color.Red("Failed to write to database")
+			return 0, storage.ErrFailedToWriteDB
+		}
+	}
 
-			resp, err := client.Do(req)
-			if err != nil {
-				color.Red("Error sending Webhook request")
-				return err
-			}
+	color.Red("Failed to number observation of %v after %d attempts", attribute, maxSequenceAttempts)
+	return 0, storage.ErrFailedToWriteDB
+}
 
-			// TODO make this more granular
-			if resp.StatusCode >= 400 && resp.StatusCode < 600 {
-				return errors.New("Error sending Webhook request")
-			}
+// connectedVendors returns the usernames of every vendor the user with the given supertypeID is associated with
+func connectedVendors(svc *dynamodb.DynamoDB, supertypeID string) (map[string]bool, error) {
+	connected := map[string]bool{}
+
+	username, err := ScanDynamoDBWithKeyCondition("user", "username", "supertypeID", supertypeID)
+	if err != nil {
+		return nil, err
+	}
+	if username == nil {
+		return connected, nil
+	}
+	result, err := GetItemDynamoDB(svc, "user", "username", *username)
+	if err != nil {
+		return nil, err
+	}
+	user := authenticating.UserWithVendors{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &user)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
+
+	for _, vendor := range user.Vendors {
+		username, err := ScanDynamoDBWithKeyCondition("vendor", "username", "pk", vendor)
+		if err != nil {
+			return nil, err
+		}
+		if username != nil {
+			connected[*username] = true
 		}
 	}
 
+	return connected, nil
+}
+
+// countObservation adds an observation to the number a vendor has produced for an attribute
+func countObservation(svc *dynamodb.DynamoDB, vendor string, attribute string) error {
+	_, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String("attributeStats"),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(vendor + "|" + attribute)},
+		},
+		UpdateExpression:          aws.String("SET #vendor = :vendor, #attribute = :attribute ADD #observations :one"),
+		ExpressionAttributeNames:  map[string]*string{"#vendor": aws.String("vendor"), "#attribute": aws.String("attribute"), "#observations": aws.String("observations")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":vendor": {S: aws.String(vendor)}, ":attribute": {S: aws.String(attribute)}, ":one": {N: aws.String("1")}},
+	})
+	if err != nil {
+		return storage.ErrFailedToWriteDB
+	}
 	return nil
 }
+
+// currentSequence returns the number of the latest observation for a user and attribute, or zero if there's none
+func currentSequence(svc *dynamodb.DynamoDB, key string) (int64, error) {
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String("sequences"),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		color.Red("Failed to read from database")
+		return 0, storage.ErrFailedToReadDB
+	}
+	if result.Item == nil || result.Item["sequence"] == nil {
+		return 0, nil
+	}
+
+	sequence, err := strconv.ParseInt(aws.StringValue(result.Item["sequence"].N), 10, 64)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return 0, storage.ErrUnmarshaling
+	}
+
+	return sequence, nil
+}
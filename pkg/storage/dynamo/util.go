@@ -7,6 +7,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
 	"github.com/fatih/color"
 	"github.com/super-type/supertype/internal/utils"
+	"github.com/super-type/supertype/pkg/dashboard"
+	"github.com/super-type/supertype/pkg/storage"
 )
 
 // GetItemDynamoDB gets an item from DynamoDB
@@ -94,20 +96,24 @@ func CheckAWSScanChain(so *dynamodb.ScanOutput, attribute string) bool {
 	return false
 }
 
-// GetSubscribersFromEndpoint moves through an endpoint to the end to the last value
-func GetSubscribersFromEndpoint(destination []string, levels interface{}) []interface{} {
-	// Register the URL granularly
-	for i := 0; i < len(destination); i++ {
-		if levels.(map[string]interface{})[destination[i]] == nil {
-			continue
-		}
-		levels = levels.(map[string]interface{})[destination[i]]
+// GetSubscription returns the webhook subscription for an endpoint, or nil if it has none
+func GetSubscription(svc *dynamodb.DynamoDB, endpoint string) (*dashboard.Subscription, error) {
+	result, err := GetItemDynamoDB(svc, "subscriptions", "endpoint", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
 	}
 
-	levels = levels.(map[string]interface{})["subscribers"]
-	urls := levels.([]interface{})
+	subscription := dashboard.Subscription{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &subscription)
+	if err != nil {
+		color.Red("Error unmarshaling data")
+		return nil, storage.ErrUnmarshaling
+	}
 
-	return urls
+	return &subscription, nil
 }
 
 // PutItemInDynamoDB adds an item to DynamoDb